@@ -4,16 +4,72 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"path/filepath"
+	"strings"
 
 	"bahmut.de/pdx-test-runner/logging"
 )
 
+// Isolation modes deciding how ignored test files are hidden from the game
+const (
+	IsolationRename  = "rename"  // Rename ignored test files in the game and mod directories
+	IsolationOverlay = "overlay" // Override ignored test files with a generated overlay mod
+)
+
+// File watching modes deciding how changes of the test results are noticed
+const (
+	FileWatchingNotify = "notify" // Filesystem notifications, with polling as a fallback
+	FileWatchingPoll   = "poll"   // Only polling, for filesystems without working notifications
+)
+
 type TestRunnerConfig struct {
-	GameDirectory   string   `json:"game-directory"`
-	ModDirectories  []string `json:"mod-directories"`
-	OutputDirectory string   `json:"output-directory"`
-	IgnoredFiles    []string `json:"ignored-files"`
-	MoveSaveGames   bool     `json:"move-save-games"`
+	GameDirectory       string                 `json:"game-directory"`
+	ModDirectories      []string               `json:"mod-directories"`
+	ApplyModDirectories bool                   `json:"apply-mod-directories"`
+	OutputDirectory     string                 `json:"output-directory"`
+	IgnoredFiles        []string               `json:"ignored-files"`
+	RunTests            []string               `json:"run"`
+	SkipTests           []string               `json:"skip"`
+	MoveSaveGames       bool                   `json:"move-save-games"`
+	ReportFormats       []string               `json:"report-formats"`
+	FailOnNoResults     bool                   `json:"fail-on-no-results"`
+	TimeoutMinutes      int                    `json:"timeout-minutes"`
+	StallTimeoutMinutes int                    `json:"stall-timeout-minutes"`
+	GracePeriodSeconds  int                    `json:"grace-period-seconds"`
+	IsolationMode       string                 `json:"isolation-mode"`
+	FileWatching        string                 `json:"file-watching"`
+	PollIntervalSeconds int                    `json:"poll-interval-seconds"`
+	Repeat              int                    `json:"repeat"`
+	BaselineDirectory   string                 `json:"baseline-directory"`
+	Instances           int                    `json:"instances"`
+	InstanceDirectory   string                 `json:"instance-directory"`
+	InstanceDataPath    string                 `json:"instance-data-path"`
+	InstanceArguments   []string               `json:"instance-arguments"`
+	InstanceEnvironment map[string]string      `json:"instance-environment"`
+	Matrix              []*MatrixConfiguration `json:"matrix"`
+}
+
+// MatrixConfiguration is a set of mods the tests are run with in matrix mode
+type MatrixConfiguration struct {
+	Name           string   `json:"name"`
+	ModDirectories []string `json:"mod-directories"`
+	IgnoredFiles   []string `json:"ignored-files"` // Replaces the ignored files of the config if set
+}
+
+// ForMatrix returns the config of a single matrix run.
+// The mods of the configuration are applied to the game and the results are written to a subfolder.
+func (config *TestRunnerConfig) ForMatrix(configuration *MatrixConfiguration) *TestRunnerConfig {
+	matrixConfig := *config
+	matrixConfig.Matrix = nil
+	matrixConfig.ModDirectories = configuration.ModDirectories
+	matrixConfig.ApplyModDirectories = true
+	// Every configuration is compared with its own previous run
+	matrixConfig.BaselineDirectory = ""
+	matrixConfig.OutputDirectory = filepath.Join(config.OutputDirectory, configuration.Name)
+	if configuration.IgnoredFiles != nil {
+		matrixConfig.IgnoredFiles = configuration.IgnoredFiles
+	}
+	return &matrixConfig
 }
 
 func LoadConfig(path string) (*TestRunnerConfig, error) {
@@ -31,7 +87,12 @@ func LoadConfig(path string) (*TestRunnerConfig, error) {
 
 	// Decode json
 	decoder := json.NewDecoder(file)
-	var config TestRunnerConfig
+	config := TestRunnerConfig{
+		// A deadlocked test keeps the game running forever, so the watchdog is enabled by default
+		StallTimeoutMinutes: 30,
+		GracePeriodSeconds:  10,
+		PollIntervalSeconds: 30,
+	}
 	err = decoder.Decode(&config)
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse config file: %w", err)
@@ -42,5 +103,62 @@ func LoadConfig(path string) (*TestRunnerConfig, error) {
 		config.OutputDirectory = "output"
 	}
 
+	if config.Repeat < 0 {
+		return nil, fmt.Errorf("repeat must not be negative")
+	}
+	if config.Repeat > 1 && len(config.Matrix) > 0 {
+		// The matrix report compares single results and can not show pass rates
+		return nil, fmt.Errorf("repeat can not be combined with matrix")
+	}
+
+	if config.Instances < 0 {
+		return nil, fmt.Errorf("instances must not be negative")
+	}
+	if config.Instances > 1 && len(config.InstanceArguments) == 0 && len(config.InstanceEnvironment) == 0 {
+		// Without them all instances would share the same data directory
+		return nil, fmt.Errorf("multiple instances need instance-arguments or instance-environment to set the data directory")
+	}
+
+	if config.TimeoutMinutes < 0 || config.StallTimeoutMinutes < 0 || config.GracePeriodSeconds < 0 {
+		return nil, fmt.Errorf("timeouts must not be negative")
+	}
+
+	// Fill optional isolation mode parameter
+	switch config.IsolationMode {
+	case "":
+		config.IsolationMode = IsolationRename
+	case IsolationRename, IsolationOverlay:
+	default:
+		return nil, fmt.Errorf("unsupported isolation mode: %s", config.IsolationMode)
+	}
+
+	// Fill optional file watching parameter
+	switch config.FileWatching {
+	case "":
+		config.FileWatching = FileWatchingNotify
+	case FileWatchingNotify, FileWatchingPoll:
+	default:
+		return nil, fmt.Errorf("unsupported file watching mode: %s", config.FileWatching)
+	}
+	if config.PollIntervalSeconds <= 0 {
+		return nil, fmt.Errorf("poll interval must be positive")
+	}
+
+	names := make(map[string]bool)
+	for _, configuration := range config.Matrix {
+		if strings.TrimSpace(configuration.Name) == "" || strings.ContainsAny(configuration.Name, `/\:*?"<>|`) {
+			return nil, fmt.Errorf("invalid matrix configuration name: %q", configuration.Name)
+		}
+		if names[configuration.Name] {
+			return nil, fmt.Errorf("duplicate matrix configuration name: %s", configuration.Name)
+		}
+		names[configuration.Name] = true
+	}
+
+	// Fill optional report formats parameter
+	if len(config.ReportFormats) == 0 {
+		config.ReportFormats = []string{"markdown"}
+	}
+
 	return &config, nil
 }
@@ -0,0 +1,173 @@
+package game
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const modDirectoryName = "mod"
+
+// Content load files by game
+var contentLoadFiles = map[Type]string{
+	Victoria3:      "content_load.json",
+	CrusaderKings3: "dlc_load.json",
+}
+
+// Attribute listing the enabled mods inside the content load file by game
+var enabledModsAttributes = map[Type]string{
+	Victoria3:      "enabledMods",
+	CrusaderKings3: "enabled_mods",
+}
+
+// ContentLoad is the selection of enabled mods (and disabled DLCs)
+// the game loads when it is started directly without the launcher.
+type ContentLoad struct {
+	Path     string
+	gameType Type
+	content  map[string]any
+}
+
+func ContentLoadPath(settings *LauncherSettings) string {
+	return filepath.Join(settings.DataPath, contentLoadFiles[settings.GameType])
+}
+
+// ReadContentLoad reads the content load file of the game.
+// A missing file results in an empty content load.
+func ReadContentLoad(settings *LauncherSettings) (*ContentLoad, error) {
+	contentLoad := &ContentLoad{
+		Path:     ContentLoadPath(settings),
+		gameType: settings.GameType,
+		content:  make(map[string]any),
+	}
+	content, err := os.ReadFile(contentLoad.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		return contentLoad, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not read content load file (%s): %v", contentLoad.Path, err)
+	}
+	err = json.Unmarshal(content, &contentLoad.content)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse content load file (%s): %v", contentLoad.Path, err)
+	}
+	return contentLoad, nil
+}
+
+// EnabledMods returns the references of all enabled mods in load order.
+// Victoria 3 references mod directories,
+// Crusader Kings 3 references mod descriptor files relative to the data path.
+func (contentLoad *ContentLoad) EnabledMods() []string {
+	mods := make([]string, 0)
+	entries, _ := contentLoad.content[enabledModsAttributes[contentLoad.gameType]].([]any)
+	for _, entry := range entries {
+		switch value := entry.(type) {
+		case string:
+			mods = append(mods, value)
+		case map[string]any:
+			if path, ok := value["path"].(string); ok {
+				mods = append(mods, path)
+			}
+		}
+	}
+	return mods
+}
+
+// AppendMod enables the referenced mod as last mod in the load order
+func (contentLoad *ContentLoad) AppendMod(reference string) {
+	attribute := enabledModsAttributes[contentLoad.gameType]
+	entries, _ := contentLoad.content[attribute].([]any)
+	switch contentLoad.gameType {
+	case Victoria3:
+		entries = append(entries, map[string]any{"path": reference})
+	default:
+		entries = append(entries, reference)
+	}
+	contentLoad.content[attribute] = entries
+}
+
+// SetEnabledMods replaces all enabled mods with the referenced mods in the given load order
+func (contentLoad *ContentLoad) SetEnabledMods(references []string) {
+	contentLoad.content[enabledModsAttributes[contentLoad.gameType]] = make([]any, 0, len(references))
+	for _, reference := range references {
+		contentLoad.AppendMod(reference)
+	}
+}
+
+func (contentLoad *ContentLoad) Write() error {
+	content, err := json.MarshalIndent(contentLoad.content, "", "\t")
+	if err != nil {
+		return fmt.Errorf("could not encode content load file: %v", err)
+	}
+	err = os.WriteFile(contentLoad.Path, content, os.ModePerm)
+	if err != nil {
+		return fmt.Errorf("could not write content load file (%s): %v", contentLoad.Path, err)
+	}
+	return nil
+}
+
+// LocalModDirectory returns the directory of a mod with the given name in the user data directory
+func LocalModDirectory(settings *LauncherSettings, name string) string {
+	return filepath.Join(settings.DataPath, modDirectoryName, name)
+}
+
+// ModDescriptorFile returns the descriptor file the game needs outside the mod directory.
+// Only Crusader Kings 3 uses such a file, for other games it is empty.
+func ModDescriptorFile(settings *LauncherSettings, modDirectory string) string {
+	if settings.GameType != CrusaderKings3 {
+		return ""
+	}
+	return filepath.Join(settings.DataPath, modDirectoryName, filepath.Base(modDirectory)+".mod")
+}
+
+// WriteModDescriptor writes the metadata files needed by the game to load the mod in the given directory.
+// It returns the reference used to enable the mod in the content load file.
+func WriteModDescriptor(settings *LauncherSettings, modDirectory, name string) (string, error) {
+	switch settings.GameType {
+	case Victoria3:
+		metadataDirectory := filepath.Join(modDirectory, ".metadata")
+		err := os.MkdirAll(metadataDirectory, os.ModePerm)
+		if err != nil {
+			return "", fmt.Errorf("could not create mod metadata directory: %v", err)
+		}
+		metadata := map[string]any{
+			"name":                   name,
+			"id":                     filepath.Base(modDirectory),
+			"version":                "1.0",
+			"supported_game_version": "",
+			"short_description":      "",
+			"tags":                   []string{},
+			"relationships":          []any{},
+			"game_custom_data": map[string]any{
+				"multiplayer_synchronized": true,
+			},
+		}
+		content, err := json.MarshalIndent(metadata, "", "\t")
+		if err != nil {
+			return "", fmt.Errorf("could not encode mod metadata: %v", err)
+		}
+		err = os.WriteFile(filepath.Join(metadataDirectory, "metadata.json"), content, os.ModePerm)
+		if err != nil {
+			return "", fmt.Errorf("could not write mod metadata: %v", err)
+		}
+		return filepath.ToSlash(modDirectory) + "/", nil
+	case CrusaderKings3:
+		descriptor := fmt.Sprintf("version=\"1.0\"\nname=\"%s\"\nsupported_version=\"*\"\n", name)
+		err := os.WriteFile(filepath.Join(modDirectory, "descriptor.mod"), []byte(descriptor), os.ModePerm)
+		if err != nil {
+			return "", fmt.Errorf("could not write mod descriptor: %v", err)
+		}
+		descriptorFile := ModDescriptorFile(settings, modDirectory)
+		descriptor += fmt.Sprintf("path=\"%s\"\n", filepath.ToSlash(modDirectory))
+		err = os.WriteFile(descriptorFile, []byte(descriptor), os.ModePerm)
+		if err != nil {
+			return "", fmt.Errorf("could not write mod descriptor: %v", err)
+		}
+		return strings.Join([]string{modDirectoryName, filepath.Base(descriptorFile)}, "/"), nil
+	default:
+		return "", fmt.Errorf("unsupported game type: %v", settings.GameType)
+	}
+}
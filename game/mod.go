@@ -0,0 +1,60 @@
+package game
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+)
+
+var regexDescriptorName = regexp.MustCompile(`(?m)^\s*name\s*=\s*"([^"]*)"`)
+
+// ModMetadata is the information about a mod read from its metadata or descriptor file
+type ModMetadata struct {
+	Name      string
+	Directory string
+}
+
+// ReadModMetadata reads the metadata of the mod in the given directory.
+// Victoria 3 uses .metadata/metadata.json and Crusader Kings 3 uses descriptor.mod.
+// Without a metadata file the directory name is used as mod name.
+func ReadModMetadata(directory string, gameType Type) (*ModMetadata, error) {
+	metadata := &ModMetadata{
+		Name:      filepath.Base(directory),
+		Directory: directory,
+	}
+	switch gameType {
+	case Victoria3:
+		content, err := os.ReadFile(filepath.Join(directory, ".metadata", "metadata.json"))
+		if errors.Is(err, os.ErrNotExist) {
+			return metadata, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("could not read mod metadata (%s): %v", directory, err)
+		}
+		var file struct {
+			Name string `json:"name"`
+		}
+		err = json.Unmarshal(content, &file)
+		if err != nil {
+			return nil, fmt.Errorf("could not parse mod metadata (%s): %v", directory, err)
+		}
+		if file.Name != "" {
+			metadata.Name = file.Name
+		}
+	case CrusaderKings3:
+		content, err := os.ReadFile(filepath.Join(directory, "descriptor.mod"))
+		if errors.Is(err, os.ErrNotExist) {
+			return metadata, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("could not read mod descriptor (%s): %v", directory, err)
+		}
+		if match := regexDescriptorName.FindSubmatch(content); match != nil && len(match[1]) > 0 {
+			metadata.Name = string(match[1])
+		}
+	}
+	return metadata, nil
+}
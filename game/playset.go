@@ -0,0 +1,153 @@
+package game
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+var regexDescriptorPath = regexp.MustCompile(`(?m)^\s*path\s*=\s*"([^"]*)"`)
+
+// ReadEnabledMods returns the metadata of all mods the game loads, in load order.
+//
+// The mods are read from the content load file, which the launcher writes
+// for the active playset and the game reads when started without the launcher.
+func ReadEnabledMods(settings *LauncherSettings) ([]*ModMetadata, error) {
+	contentLoad, err := ReadContentLoad(settings)
+	if err != nil {
+		return nil, err
+	}
+	mods := make([]*ModMetadata, 0)
+	for _, reference := range contentLoad.EnabledMods() {
+		directory, err := ResolveModReference(settings, reference)
+		if err != nil {
+			return nil, err
+		}
+		metadata, err := ReadModMetadata(directory, settings.GameType)
+		if err != nil {
+			return nil, err
+		}
+		mods = append(mods, metadata)
+	}
+	return mods, nil
+}
+
+// ResolveModReference returns the mod directory of a reference in the content load file.
+// Victoria 3 references the mod directory directly,
+// Crusader Kings 3 references a descriptor file containing the path of the mod directory.
+// Relative paths are resolved against the data path.
+func ResolveModReference(settings *LauncherSettings, reference string) (string, error) {
+	switch settings.GameType {
+	case Victoria3:
+		return dataRelativePath(settings, reference), nil
+	case CrusaderKings3:
+		descriptorFile := dataRelativePath(settings, reference)
+		content, err := os.ReadFile(descriptorFile)
+		if errors.Is(err, os.ErrNotExist) {
+			return "", fmt.Errorf("enabled mod descriptor does not exist: %s", descriptorFile)
+		}
+		if err != nil {
+			return "", fmt.Errorf("could not read mod descriptor (%s): %v", descriptorFile, err)
+		}
+		match := regexDescriptorPath.FindSubmatch(content)
+		if match == nil || len(match[1]) == 0 {
+			return "", fmt.Errorf("mod descriptor has no path: %s", descriptorFile)
+		}
+		return dataRelativePath(settings, string(match[1])), nil
+	default:
+		return "", fmt.Errorf("unsupported game type: %v", settings.GameType)
+	}
+}
+
+// ModReference returns the reference used to enable the mod in the given directory in the content load file.
+// Crusader Kings 3 needs a descriptor file pointing to the mod directory in the data path,
+// which is created by the launcher when the mod is installed.
+func ModReference(settings *LauncherSettings, directory string) (string, error) {
+	switch settings.GameType {
+	case Victoria3:
+		return filepath.ToSlash(filepath.Clean(directory)) + "/", nil
+	case CrusaderKings3:
+		descriptorFiles, err := filepath.Glob(filepath.Join(settings.DataPath, modDirectoryName, "*.mod"))
+		if err != nil {
+			return "", fmt.Errorf("could not list mod descriptors: %v", err)
+		}
+		for _, descriptorFile := range descriptorFiles {
+			reference := strings.Join([]string{modDirectoryName, filepath.Base(descriptorFile)}, "/")
+			modDirectory, err := ResolveModReference(settings, reference)
+			if err != nil {
+				// Broken descriptors of other mods do not matter
+				continue
+			}
+			if SameModDirectory(modDirectory, directory) {
+				return reference, nil
+			}
+		}
+		return "", fmt.Errorf("no mod descriptor in %s points to mod directory: %s", filepath.Join(settings.DataPath, modDirectoryName), directory)
+	default:
+		return "", fmt.Errorf("unsupported game type: %v", settings.GameType)
+	}
+}
+
+// RelocateModReference returns a reference to the same mod that is valid in the data path of target.
+// Victoria 3 references are made absolute,
+// Crusader Kings 3 descriptor files are copied to target with an absolute mod path.
+func RelocateModReference(source, target *LauncherSettings, reference string) (string, error) {
+	directory, err := ResolveModReference(source, reference)
+	if err != nil {
+		return "", err
+	}
+	absoluteDirectory, err := filepath.Abs(directory)
+	if err != nil {
+		return "", fmt.Errorf("invalid mod directory (%s): %v", directory, err)
+	}
+	switch source.GameType {
+	case Victoria3:
+		return filepath.ToSlash(absoluteDirectory) + "/", nil
+	case CrusaderKings3:
+		descriptorFile := dataRelativePath(source, reference)
+		content, err := os.ReadFile(descriptorFile)
+		if err != nil {
+			return "", fmt.Errorf("could not read mod descriptor (%s): %v", descriptorFile, err)
+		}
+		content = regexDescriptorPath.ReplaceAllLiteral(content, []byte(fmt.Sprintf("path=\"%s\"", filepath.ToSlash(absoluteDirectory))))
+		targetFile := filepath.Join(target.DataPath, modDirectoryName, filepath.Base(descriptorFile))
+		err = os.MkdirAll(filepath.Dir(targetFile), os.ModePerm)
+		if err != nil {
+			return "", fmt.Errorf("could not create mod directory: %v", err)
+		}
+		err = os.WriteFile(targetFile, content, os.ModePerm)
+		if err != nil {
+			return "", fmt.Errorf("could not write mod descriptor (%s): %v", targetFile, err)
+		}
+		return strings.Join([]string{modDirectoryName, filepath.Base(targetFile)}, "/"), nil
+	default:
+		return "", fmt.Errorf("unsupported game type: %v", source.GameType)
+	}
+}
+
+// SameModDirectory reports whether both paths point to the same mod directory
+func SameModDirectory(first, second string) bool {
+	first, second = filepath.Clean(first), filepath.Clean(second)
+	if absolute, err := filepath.Abs(first); err == nil {
+		first = absolute
+	}
+	if absolute, err := filepath.Abs(second); err == nil {
+		second = absolute
+	}
+	if filepath.Separator == '\\' {
+		// Windows paths are case-insensitive
+		return strings.EqualFold(first, second)
+	}
+	return first == second
+}
+
+func dataRelativePath(settings *LauncherSettings, path string) string {
+	path = filepath.FromSlash(strings.TrimSpace(path))
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(settings.DataPath, path)
+	}
+	return filepath.Clean(path)
+}
@@ -1,11 +1,16 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"os"
+	"os/signal"
 	"path/filepath"
 	"strings"
+	"syscall"
+	"time"
 
 	"bahmut.de/pdx-test-runner/config"
 	"bahmut.de/pdx-test-runner/game"
@@ -15,62 +20,299 @@ import (
 )
 
 const (
-	FlagConfig        = "config"
-	FlagReportIgnored = "report-ignored"
+	FlagConfig          = "config"
+	FlagReportIgnored   = "report-ignored"
+	FlagReportFormat    = "report-format"
+	FlagFailOnNoResults = "fail-on-no-results"
+	FlagRun             = "run"
+	FlagSkip            = "skip"
+	FlagRepeat          = "repeat"
+	FlagLast            = "last"
+	FlagBaseline        = "baseline"
+)
+
+const (
+	CommandRun     = "run"
+	CommandRestore = "restore"
+	CommandLint    = "lint"
+	CommandHistory = "history"
+	CommandDiff    = "diff"
+)
+
+// Process exit codes
+const (
+	ExitCodeSuccess      = 0 // All active tests passed
+	ExitCodeTestsFailed  = 1 // At least one test failed
+	ExitCodeTestsMissing = 2 // At least one active test has no result
+	ExitCodeError        = 3 // The runner itself failed
 )
 
 func main() {
+	os.Exit(run())
+}
+
+func run() (code int) {
+	command := CommandRun
+	arguments := os.Args[1:]
+	if len(arguments) > 0 && !strings.HasPrefix(arguments[0], "-") {
+		command = arguments[0]
+		arguments = arguments[1:]
+	}
+
+	// Usage errors must not exit with 2, which means tests are missing
+	flag.CommandLine.Init(os.Args[0], flag.ContinueOnError)
 	configFlag := flag.String(FlagConfig, "test-config.json", "Optional: Path to test config")
 	reportIgnored := flag.Bool(FlagReportIgnored, false, "Optional: Enable to list ignored tests in console")
-	flag.Parse()
+	reportFormat := flag.String(FlagReportFormat, "", "Optional: Comma separated list of report formats (markdown, junit, json, html) (overrides config)")
+	failOnNoResults := flag.Bool(FlagFailOnNoResults, false, "Optional: Enable to treat a run without any matched test results as failure")
+	var runPatterns, skipPatterns patternList
+	flag.Var(&runPatterns, FlagRun, "Optional: Only run tests matching the pattern (can be repeated) (overrides config)")
+	flag.Var(&skipPatterns, FlagSkip, "Optional: Skip tests matching the pattern (can be repeated) (overrides config)")
+	repeat := flag.Int(FlagRepeat, 0, "Optional: Run the tests this many times and report pass rates (overrides config)")
+	last := flag.Int(FlagLast, 10, "Optional: Number of recent test runs shown by the history command")
+	baselineDirectory := flag.String(FlagBaseline, "", "Optional: Output directory of the test run the results are compared with (overrides config)")
+	flag.Usage = usage
+	err := flag.CommandLine.Parse(arguments)
+	if errors.Is(err, flag.ErrHelp) {
+		return ExitCodeSuccess
+	}
+	if err != nil {
+		// The flag package already printed the error and the usage
+		return ExitCodeError
+	}
+	switch command {
+	case CommandRun, CommandRestore, CommandLint, CommandHistory, CommandDiff:
+	default:
+		logging.Errorf("Unknown command: %s", command)
+		flag.Usage()
+		return ExitCodeError
+	}
+	if command != CommandDiff && flag.NArg() > 0 {
+		// Commands have to be given before the flags, otherwise they end up here
+		logging.Errorf("Unexpected arguments: %s", strings.Join(flag.Args(), " "))
+		flag.Usage()
+		return ExitCodeError
+	}
 
 	configPath, err := filepath.Abs(*configFlag)
 	if err != nil {
-		logging.Fatalf("Provided config file path is invalid: %s", err)
-		os.Exit(1)
+		logging.Errorf("Provided config file path is invalid: %s", err)
+		return ExitCodeError
 	}
 	if _, err := os.Stat(configPath); os.IsNotExist(err) {
-		logging.Fatalf("Config file does not exist: %s", configPath)
-		os.Exit(1)
+		logging.Errorf("Config file does not exist: %s", configPath)
+		return ExitCodeError
 	}
 
 	logging.Info("Loading Runner Config")
 	testConfig, err := config.LoadConfig(configPath)
 	if err != nil {
-		logging.Fatalf("Could not load config file: %s", err)
-		os.Exit(1)
+		logging.Errorf("Could not load config file: %s", err)
+		return ExitCodeError
+	}
+	if strings.TrimSpace(*reportFormat) != "" {
+		testConfig.ReportFormats = strings.Split(*reportFormat, ",")
+		for i, format := range testConfig.ReportFormats {
+			testConfig.ReportFormats[i] = strings.TrimSpace(format)
+		}
+	}
+	if *failOnNoResults {
+		testConfig.FailOnNoResults = true
+	}
+	if len(runPatterns) > 0 {
+		testConfig.RunTests = runPatterns
+	}
+	if len(skipPatterns) > 0 {
+		testConfig.SkipTests = skipPatterns
+	}
+	if *repeat > 0 {
+		testConfig.Repeat = *repeat
+	}
+	if testConfig.Repeat > 1 && len(testConfig.Matrix) > 0 {
+		logging.Errorf("Option -%s can not be combined with matrix runs", FlagRepeat)
+		return ExitCodeError
+	}
+	if *baselineDirectory != "" {
+		testConfig.BaselineDirectory = *baselineDirectory
+	}
+	err = reporting.ValidateFormats(testConfig.ReportFormats)
+	if err != nil {
+		logging.Errorf("Invalid report format: %s", err)
+		return ExitCodeError
+	}
+
+	if command == CommandHistory {
+		return history(testConfig, *last)
+	}
+	if command == CommandDiff {
+		return diff(testConfig, flag.Args())
+	}
+
+	journal, err := testing.OpenJournal(testConfig.OutputDirectory)
+	if err != nil {
+		logging.Errorf("Could not open restore journal: %s", err)
+		return ExitCodeError
+	}
+	if journal.OwnerRunning() {
+		// Restoring would undo the changes of the running test run
+		if command != CommandLint {
+			logging.Errorf("Restore journal belongs to a test run that is still running (process %v): %s", journal.Owner, journal.Path())
+			return ExitCodeError
+		}
+		logging.Warnf("Another test run is in progress (process %v), test files may be deactivated", journal.Owner)
+	}
+	if command == CommandRestore {
+		return restore(journal)
+	}
+	if journal.Stale() {
+		logging.Warnf("Found restore journal of an unfinished test run: %s", journal.Path())
+		if restore(journal) != ExitCodeSuccess {
+			return ExitCodeError
+		}
 	}
 
 	logging.Info("Loading Game Settings")
 	settings, err := game.GetLauncherSettings(testConfig.GameDirectory)
 	if err != nil {
-		logging.Fatalf("Could not load game launcher settings: %s", err)
-		os.Exit(1)
+		logging.Errorf("Could not load game launcher settings: %s", err)
+		return ExitCodeError
 	}
 
+	err = resolveModDirectories(settings, testConfig)
+	if err != nil {
+		logging.Errorf("Could not read enabled mods: %s", err)
+		return ExitCodeError
+	}
+
+	if command == CommandLint {
+		return lint(settings, testConfig)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		// A second interrupt terminates the runner immediately
+		stop()
+	}()
+
+	if len(testConfig.Matrix) > 0 {
+		return runMatrix(ctx, settings, testConfig, journal, reportIgnored != nil && *reportIgnored)
+	}
+	_, _, code = runConfiguration(ctx, settings, testConfig, journal, reportIgnored != nil && *reportIgnored)
+	return code
+}
+
+// runConfiguration runs the tests once with the given config and writes the reports.
+// All changed test and game files are restored before it returns.
+func runConfiguration(ctx context.Context, settings *game.LauncherSettings, testConfig *config.TestRunnerConfig, journal *testing.Journal, reportIgnored bool) (results *testing.ExecutionResults, testFiles []*testing.PdxTestFile, code int) {
 	logging.Info("Reading Tests")
 	testFiles, err := testing.GetTestFiles(settings.ContentPath, testConfig.ModDirectories, settings.GameType)
 	if err != nil {
-		logging.Fatalf("Could not parse tests: %s", err)
-		os.Exit(1)
+		logging.Errorf("Could not parse tests: %s", err)
+		return nil, nil, ExitCodeError
+	}
+
+	err = testing.SelectTests(testFiles, testConfig.RunTests, testConfig.SkipTests)
+	if err != nil {
+		logging.Errorf("Could not select tests: %s", err)
+		return nil, nil, ExitCodeError
+	}
+	if len(testConfig.RunTests)+len(testConfig.SkipTests) > 0 && !hasActiveTests(testFiles) {
+		// Starting the game without any test to run would only waste time
+		logging.Errorf("No test matches the run and skip patterns")
+		return nil, nil, ExitCodeTestsMissing
+	}
+
+	// Test files have to be restored in any case, even if the run fails or is interrupted
+	defer func() {
+		logging.Info("Reactivating all test files")
+		var err error
+		if testConfig.IsolationMode == config.IsolationOverlay {
+			// The overlay does not rename test files, so only the recorded changes are restored.
+			// Renaming would also activate test files deactivated outside the test run.
+			err = journal.Restore()
+		} else {
+			err = testing.ActivateTestFiles(testFiles, journal)
+		}
+		if err != nil {
+			logging.Errorf("Could not activate test files: %s", err)
+			code = ExitCodeError
+		}
+	}()
+
+	if testConfig.ApplyModDirectories {
+		logging.Infof("Enabling %v configured mods", len(testConfig.ModDirectories))
+		err = testing.EnableModDirectories(settings, testConfig.ModDirectories, journal)
+		if err != nil {
+			logging.Errorf("Could not enable configured mods: %s", err)
+			return nil, testFiles, ExitCodeError
+		}
 	}
 
 	logging.Info("Deactivating ignored test files")
-	err = testing.DeactivateTestFiles(testFiles, testConfig.IgnoredFiles)
+	switch testConfig.IsolationMode {
+	case config.IsolationOverlay:
+		err = testing.DeactivateTestFilesWithOverlay(settings, testFiles, testConfig.IgnoredFiles, journal)
+	default:
+		err = testing.DeactivateTestFiles(testFiles, testConfig.IgnoredFiles, journal)
+	}
 	if err != nil {
-		logging.Fatalf("Could not deactivate ignored test files: %s", err)
-		os.Exit(1)
+		logging.Errorf("Could not deactivate ignored test files: %s", err)
+		return nil, testFiles, ExitCodeError
+	}
+
+	logging.Info(buildFoundTestsReport(testFiles, reportIgnored))
+	if reportIgnored && len(testConfig.IgnoredFiles) > 0 {
+		logging.Info(buildIgnorePatternReport(testFiles, testConfig.IgnoredFiles))
 	}
 
-	logging.Info(buildFoundTestsReport(testFiles, reportIgnored != nil && *reportIgnored))
+	repeat := max(testConfig.Repeat, 1)
+	startTime := time.Now()
+	runs := make([]*testing.ExecutionResults, 0, repeat)
+	for i := 1; i <= repeat && ctx.Err() == nil; i++ {
+		if repeat > 1 {
+			logging.Infof("Start running tests (run %v of %v)", i, repeat)
+		} else {
+			logging.Info("Start running tests")
+		}
+		var ok bool
+		results, ok = runTestsOnce(ctx, settings, testConfig, testFiles, journal)
+		if !ok {
+			return results, testFiles, ExitCodeError
+		}
+		runs = append(runs, results)
+	}
+	if repeat == 1 {
+		return results, testFiles, exitCode(results, testFiles, testConfig.FailOnNoResults)
+	}
 
-	logging.Info("Start running tests")
-	results, err := testing.RunTests(settings, testConfig, testFiles)
+	statistics := testing.AggregateResults(runs, testFiles)
+	logging.Info(buildStatisticsReport(statistics, len(runs)))
+	reportFile, err := reporting.WriteStatisticsReport(testConfig.OutputDirectory, startTime, runs, statistics, settings)
 	if err != nil {
-		logging.Fatalf("Could not run tests: %s", err)
-		os.Exit(1)
+		logging.Errorf("Could not write statistics report: %s", err)
+		return results, testFiles, ExitCodeError
 	}
-	logging.Info("Finished running tests")
+	logging.Infof("Statistics report: %s", reportFile)
+	return results, testFiles, repeatExitCode(runs, statistics, repeat)
+}
+
+// runTestsOnce starts the game once and writes the reports of the test run.
+// Errors are logged, the returned flag reports whether the test run and its reports succeeded.
+func runTestsOnce(ctx context.Context, settings *game.LauncherSettings, testConfig *config.TestRunnerConfig, testFiles []*testing.PdxTestFile, journal *testing.Journal) (*testing.ExecutionResults, bool) {
+	var results *testing.ExecutionResults
+	var err error
+	if testConfig.Instances > 1 {
+		results, err = testing.RunTestsInParallel(ctx, settings, testConfig, testFiles, journal)
+	} else {
+		results, err = testing.RunTests(ctx, settings, testConfig, testFiles)
+	}
+	if err != nil {
+		logging.Errorf("Could not run tests: %s", err)
+		return nil, false
+	}
+	logging.Infof("Finished running tests: %s", results.Outcome)
 	logging.Infof("Running tests took: %s", results.Duration.String())
 
 	absoluteOutputPath, err := filepath.Abs(results.OutputDirectory)
@@ -81,31 +323,406 @@ func main() {
 	}
 	logging.Info(buildRunTestsReport(results))
 
-	logging.Info("Writing report")
-	err = reporting.WriteReport(results, testFiles, settings)
+	current := testing.NewHistoryRun(results, testFiles)
+	compareWithBaseline(testConfig, results, current)
+	if results.Diff != nil {
+		logging.Info(buildDiffReport(results.Baseline, results.Diff))
+	}
+
+	logging.Info("Writing reports")
+	err = reporting.WriteReports(testConfig.ReportFormats, results, testFiles, settings)
+	if err != nil {
+		logging.Errorf("Could not write report: %s", err)
+		return results, false
+	}
+	err = testing.AppendHistory(testConfig.OutputDirectory, current)
+	if err != nil {
+		logging.Errorf("Could not record test run in history: %s", err)
+		return results, false
+	}
+	return results, true
+}
+
+// runMatrix runs the tests once for every matrix configuration and writes a report comparing them.
+// The exit code is the most severe exit code of all runs.
+func runMatrix(ctx context.Context, settings *game.LauncherSettings, testConfig *config.TestRunnerConfig, journal *testing.Journal, reportIgnored bool) int {
+	startTime := time.Now()
+	code := ExitCodeSuccess
+	runs := make([]*reporting.MatrixRun, 0, len(testConfig.Matrix))
+	for _, configuration := range testConfig.Matrix {
+		if ctx.Err() != nil {
+			logging.Warnf("Skipping matrix configuration %s after interrupt", configuration.Name)
+			code = ExitCodeError
+			continue
+		}
+		logging.Infof("Running matrix configuration %s%s%s", logging.AnsiBoldOn, configuration.Name, logging.AnsiAllDefault)
+		results, testFiles, runCode := runConfiguration(ctx, settings, testConfig.ForMatrix(configuration), journal, reportIgnored)
+		runs = append(runs, &reporting.MatrixRun{
+			Name:           configuration.Name,
+			ModDirectories: configuration.ModDirectories,
+			Results:        results,
+			TestFiles:      testFiles,
+		})
+		code = max(code, runCode)
+	}
+
+	logging.Info("Writing matrix report")
+	reportFile, err := reporting.WriteMatrixReport(testConfig.OutputDirectory, startTime, runs, settings)
+	if err != nil {
+		logging.Errorf("Could not write matrix report: %s", err)
+		return ExitCodeError
+	}
+	logging.Infof("Matrix report: %s", reportFile)
+	return code
+}
+
+func usage() {
+	output := flag.CommandLine.Output()
+	_, _ = fmt.Fprintf(output, "Usage of %s: [command] [options]\n", filepath.Base(os.Args[0]))
+	_, _ = fmt.Fprintln(output, "Commands:")
+	_, _ = fmt.Fprintf(output, "  %s\n    \tRun all active tests (default)\n", CommandRun)
+	_, _ = fmt.Fprintf(output, "  %s\n    \tRestore test files changed by an unfinished test run\n", CommandRestore)
+	_, _ = fmt.Fprintf(output, "  %s\n    \tCheck all scripted test files for mistakes without running the game\n", CommandLint)
+	_, _ = fmt.Fprintf(output, "  %s\n    \tShow the results of every test in the recent test runs\n", CommandHistory)
+	_, _ = fmt.Fprintf(output, "  %s [baseline] [current]\n    \tCompare the results of two test runs (default: the last two runs)\n", CommandDiff)
+	_, _ = fmt.Fprintln(output, "Options:")
+	flag.PrintDefaults()
+}
+
+func restore(journal *testing.Journal) int {
+	if !journal.Stale() {
+		logging.Info("Nothing to restore")
+		return ExitCodeSuccess
+	}
+	logging.Infof("Restoring %v changed test files", len(journal.Entries))
+	err := journal.Restore()
+	if err != nil {
+		logging.Errorf("Could not restore test files: %s", err)
+		return ExitCodeError
+	}
+	logging.Info("Restored all test files")
+	return ExitCodeSuccess
+}
+
+// history shows the recent results of every test recorded in the run history.
+// Matrix configurations have their own history in their output subfolder.
+func history(testConfig *config.TestRunnerConfig, last int) int {
+	if len(testConfig.Matrix) == 0 {
+		return showHistory(testConfig.OutputDirectory, last)
+	}
+	code := ExitCodeSuccess
+	for _, configuration := range testConfig.Matrix {
+		logging.Infof("Matrix configuration %s%s%s", logging.AnsiBoldOn, configuration.Name, logging.AnsiAllDefault)
+		code = max(code, showHistory(testConfig.ForMatrix(configuration).OutputDirectory, last))
+	}
+	return code
+}
+
+func showHistory(outputDirectory string, last int) int {
+	runs, err := testing.ReadHistory(outputDirectory)
+	if err != nil {
+		logging.Errorf("Could not read run history: %s", err)
+		return ExitCodeError
+	}
+	if len(runs) == 0 {
+		logging.Infof("No test runs recorded in %s", outputDirectory)
+		return ExitCodeSuccess
+	}
+	logging.Info(buildHistoryReport(runs, testing.SummarizeHistory(runs, max(last, 1))))
+	return ExitCodeSuccess
+}
+
+// compareWithBaseline compares the test run with the configured baseline run,
+// or with the previous run in the history if no baseline is configured.
+// Without any baseline run the results stay without comparison.
+func compareWithBaseline(testConfig *config.TestRunnerConfig, results *testing.ExecutionResults, current *testing.HistoryRun) {
+	var baseline *testing.HistoryRun
+	var err error
+	if testConfig.BaselineDirectory != "" {
+		baseline, err = loadRun(testConfig.OutputDirectory, testConfig.BaselineDirectory)
+	} else {
+		baseline, err = previousRun(testConfig.OutputDirectory, 0)
+	}
+	if err != nil {
+		logging.Warnf("Could not load baseline run: %s", err)
+		return
+	}
+	if baseline == nil {
+		return
+	}
+	results.Baseline = baseline.OutputDirectory
+	results.Diff = testing.DiffRuns(baseline, current)
+}
+
+// diff compares two test runs given as output directories.
+// Without directories the latest run is compared with the baseline run or the run before it.
+func diff(testConfig *config.TestRunnerConfig, directories []string) int {
+	if len(directories) > 2 {
+		logging.Errorf("Too many arguments for %s: %s", CommandDiff, strings.Join(directories, " "))
+		return ExitCodeError
+	}
+
+	var current *testing.HistoryRun
+	var err error
+	if len(directories) == 2 {
+		current, err = loadRun(testConfig.OutputDirectory, directories[1])
+	} else {
+		current, err = previousRun(testConfig.OutputDirectory, 0)
+	}
+	if err == nil && current == nil {
+		err = fmt.Errorf("no test runs recorded in %s", testConfig.OutputDirectory)
+	}
+	if err != nil {
+		logging.Errorf("Could not load test run: %s", err)
+		return ExitCodeError
+	}
+
+	var baseline *testing.HistoryRun
+	switch {
+	case len(directories) > 0:
+		baseline, err = loadRun(testConfig.OutputDirectory, directories[0])
+	case testConfig.BaselineDirectory != "":
+		baseline, err = loadRun(testConfig.OutputDirectory, testConfig.BaselineDirectory)
+	default:
+		baseline, err = previousRun(testConfig.OutputDirectory, 1)
+	}
+	if err == nil && baseline == nil {
+		err = fmt.Errorf("no earlier test run recorded in %s", testConfig.OutputDirectory)
+	}
+	if err != nil {
+		logging.Errorf("Could not load baseline run: %s", err)
+		return ExitCodeError
+	}
+
+	logging.Infof("Comparing %s with %s", current.OutputDirectory, baseline.OutputDirectory)
+	diffs := testing.DiffRuns(baseline, current)
+	logging.Info(buildDiffReport(baseline.OutputDirectory, diffs))
+	for _, testDiff := range diffs {
+		if testDiff.Status == testing.DiffNewlyFailing {
+			return ExitCodeTestsFailed
+		}
+	}
+	return ExitCodeSuccess
+}
+
+// loadRun loads a test run from the json report in its output directory,
+// or from the run history if the test run has no json report
+func loadRun(outputDirectory, directory string) (*testing.HistoryRun, error) {
+	report, err := reporting.ReadJSONReport(directory)
+	if err == nil {
+		run := report.HistoryRun()
+		run.OutputDirectory = directory
+		return run, nil
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	runs, err := testing.ReadHistory(outputDirectory)
+	if err != nil {
+		return nil, err
+	}
+	absoluteDirectory, _ := filepath.Abs(directory)
+	for _, run := range runs {
+		if absoluteRun, _ := filepath.Abs(run.OutputDirectory); absoluteRun == absoluteDirectory {
+			return run, nil
+		}
+	}
+	return nil, fmt.Errorf("%s has no json report and is not recorded in the run history", directory)
+}
+
+// previousRun returns the recorded run with the given distance from the latest run (nil if there is none)
+func previousRun(outputDirectory string, distance int) (*testing.HistoryRun, error) {
+	runs, err := testing.ReadHistory(outputDirectory)
 	if err != nil {
-		logging.Fatalf("Could not write report: %s", err)
-		os.Exit(1)
+		return nil, err
 	}
+	if len(runs) <= distance {
+		return nil, nil
+	}
+	return runs[len(runs)-1-distance], nil
+}
 
-	logging.Info("Reactivating all test files")
-	err = testing.ActivateTestFiles(testFiles)
+func lint(settings *game.LauncherSettings, testConfig *config.TestRunnerConfig) int {
+	logging.Info("Checking Tests")
+	issues, err := testing.LintTestFiles(settings.ContentPath, testConfig.ModDirectories, settings.GameType)
 	if err != nil {
-		logging.Fatalf("Could not activate test files: %s", err)
-		os.Exit(1)
+		logging.Errorf("Could not check tests: %s", err)
+		return ExitCodeError
+	}
+	for _, issue := range issues {
+		logging.Error(issue)
+	}
+	if len(issues) > 0 {
+		logging.Errorf("Found %s%v%s problems in scripted test files", logging.AnsiBoldOn, len(issues), logging.AnsiAllDefault)
+		return ExitCodeTestsFailed
+	}
+	logging.Info("No problems found in scripted test files")
+	return ExitCodeSuccess
+}
+
+// resolveModDirectories uses the mods enabled in the game if no mod directories are configured
+// and the configured mods are not applied to the game. Otherwise, it warns about differences between the configured and the enabled mods.
+func resolveModDirectories(settings *game.LauncherSettings, testConfig *config.TestRunnerConfig) error {
+	if testConfig.ApplyModDirectories || len(testConfig.Matrix) > 0 {
+		// The configured mods replace the enabled mods before the game is started
+		return nil
+	}
+
+	enabledMods, err := game.ReadEnabledMods(settings)
+	if err != nil {
+		if len(testConfig.ModDirectories) == 0 {
+			return err
+		}
+		logging.Warnf("Could not compare configured mods with enabled mods: %s", err)
+		return nil
+	}
+
+	if len(testConfig.ModDirectories) == 0 {
+		names := make([]string, 0, len(enabledMods))
+		for _, mod := range enabledMods {
+			testConfig.ModDirectories = append(testConfig.ModDirectories, mod.Directory)
+			names = append(names, mod.Name)
+		}
+		if len(enabledMods) > 0 {
+			logging.Infof("Using %v mods enabled in the game: %s", len(enabledMods), strings.Join(names, ", "))
+		}
+		return nil
+	}
+
+	for _, directory := range testConfig.ModDirectories {
+		if enabledModIndex(enabledMods, directory) < 0 {
+			logging.Warnf("Configured mod is not enabled in the game, its tests will not run: %s", directory)
+		}
+	}
+	order := make([]int, 0)
+	for _, mod := range enabledMods {
+		index := -1
+		for i, directory := range testConfig.ModDirectories {
+			if game.SameModDirectory(directory, mod.Directory) {
+				index = i
+				break
+			}
+		}
+		if index < 0 {
+			logging.Warnf("Mod %s is enabled in the game but not configured: %s", mod.Name, mod.Directory)
+			continue
+		}
+		order = append(order, index)
+	}
+	for i := 1; i < len(order); i++ {
+		if order[i] < order[i-1] {
+			logging.Warn("Configured mods are not in the load order of the game, overridden test files may be reported wrongly")
+			break
+		}
+	}
+	return nil
+}
+
+func enabledModIndex(enabledMods []*game.ModMetadata, directory string) int {
+	for i, mod := range enabledMods {
+		if game.SameModDirectory(directory, mod.Directory) {
+			return i
+		}
+	}
+	return -1
+}
+
+func exitCode(results *testing.ExecutionResults, files []*testing.PdxTestFile, failOnNoResults bool) int {
+	if results.Outcome != testing.OutcomeCompleted {
+		logging.Errorf("Test run did not complete: %s", results.Outcome)
+		return ExitCodeError
+	}
+
+	for _, testResult := range results.TestResults {
+		if !testResult.Success {
+			return ExitCodeTestsFailed
+		}
+	}
+
+	missing := testing.MissingTests(results, files)
+	if len(missing) > 0 {
+		for _, test := range missing {
+			logging.Warnf("No result found for test: %s", test.Name)
+		}
+		return ExitCodeTestsMissing
 	}
 
+	if len(results.TestResults) == 0 && failOnNoResults {
+		logging.Warn("No test results could be matched to parsed tests")
+		return ExitCodeTestsMissing
+	}
+
+	return ExitCodeSuccess
+}
+
+// repeatExitCode decides the exit code of repeated test runs.
+// Tests only count as failed if they failed more often than their acceptable fail rate.
+func repeatExitCode(runs []*testing.ExecutionResults, statistics []*testing.TestStatistics, repeat int) int {
+	if len(runs) < repeat {
+		logging.Errorf("Only %v of %v test runs were started", len(runs), repeat)
+		return ExitCodeError
+	}
+	for _, results := range runs {
+		if results.Outcome != testing.OutcomeCompleted {
+			logging.Errorf("Test run did not complete: %s", results.Outcome)
+			return ExitCodeError
+		}
+	}
+
+	for _, testStatistics := range statistics {
+		if testStatistics.ExceedsTolerance() {
+			return ExitCodeTestsFailed
+		}
+	}
+
+	for _, testStatistics := range statistics {
+		if testStatistics.Missing > 0 {
+			logging.Warnf("No result found in %v runs for test: %s", testStatistics.Missing, testStatistics.Test.Name)
+			return ExitCodeTestsMissing
+		}
+	}
+
+	return ExitCodeSuccess
+}
+
+// patternList collects the values of a repeatable flag
+type patternList []string
+
+func (patterns *patternList) String() string {
+	return strings.Join(*patterns, ", ")
+}
+
+func (patterns *patternList) Set(value string) error {
+	*patterns = append(*patterns, value)
+	return nil
+}
+
+func hasActiveTests(files []*testing.PdxTestFile) bool {
+	for _, testFile := range files {
+		for _, test := range testFile.Tests {
+			if testFile.IsTestActive(test) {
+				return true
+			}
+		}
+	}
+	return false
 }
 
 func buildFoundTestsReport(files []*testing.PdxTestFile, ignored bool) string {
 	countFiles := 0
 	countTests := 0
 	for _, testFile := range files {
-		if testFile.Ignored == !ignored {
-			continue
+		counted := false
+		for _, test := range testFile.Tests {
+			if testFile.IsTestActive(test) == ignored {
+				continue
+			}
+			countTests++
+			if !counted {
+				countFiles++
+				counted = true
+			}
 		}
-		countFiles++
-		countTests += len(testFile.Tests)
 	}
 
 	var report string
@@ -121,16 +738,17 @@ func buildFoundTestsReport(files []*testing.PdxTestFile, ignored bool) string {
 	)
 
 	for _, testFile := range files {
-		if testFile.Ignored && !ignored {
-			continue
-		}
-		var color string
-		if testFile.Ignored {
-			color = logging.AnsiFgLightRed
-		} else {
-			color = logging.AnsiFgBlue
-		}
 		for _, test := range testFile.Tests {
+			active := testFile.IsTestActive(test)
+			if !active && !ignored {
+				continue
+			}
+			var color string
+			if active {
+				color = logging.AnsiFgBlue
+			} else {
+				color = logging.AnsiFgLightRed
+			}
 			if strings.TrimSpace(test.DisplayName) != "" {
 				report += fmt.Sprintf(
 					"\n - %sTest:%s %s%s%s (%s)",
@@ -170,6 +788,150 @@ func buildFoundTestsReport(files []*testing.PdxTestFile, ignored bool) string {
 					logging.AnsiAllDefault,
 				)
 			}
+			report += fmt.Sprintf(" [%s]", testFile.Origin())
+		}
+	}
+	return report
+}
+
+func buildStatisticsReport(statistics []*testing.TestStatistics, runs int) string {
+	exceeding := 0
+	for _, testStatistics := range statistics {
+		if testStatistics.ExceedsTolerance() {
+			exceeding++
+		}
+	}
+	report := fmt.Sprintf(
+		"Statistics of %s%v%s runs, %s%v%s tests failed more often than tolerated:",
+		logging.AnsiBoldOn, runs, logging.AnsiAllDefault,
+		logging.AnsiBoldOn, exceeding, logging.AnsiAllDefault,
+	)
+	for _, testStatistics := range statistics {
+		color := logging.AnsiFgGreen
+		if testStatistics.ExceedsTolerance() {
+			color = logging.AnsiFgLightRed
+		} else if testStatistics.Classification() != testing.ClassificationStable {
+			color = logging.AnsiFgYellow
+		}
+		lower, upper := testStatistics.ConfidenceInterval()
+		report += fmt.Sprintf(
+			"\n - %s%s%s :: %s%s%s :: passed %v/%v (%.1f%%, 95%% CI %.1f%% – %.1f%%)",
+			logging.AnsiFgBlue, testStatistics.Test.Name, logging.AnsiAllDefault,
+			color, testStatistics.Classification(), logging.AnsiAllDefault,
+			testStatistics.Successes, testStatistics.Runs(),
+			testStatistics.PassRate()*100, lower*100, upper*100,
+		)
+		if testStatistics.Test.AcceptableFailRate > 0 {
+			report += fmt.Sprintf(" :: tolerance %v%%", testStatistics.Test.AcceptableFailRate*100)
+		}
+		if testStatistics.Missing > 0 {
+			report += fmt.Sprintf(" :: %v runs without result", testStatistics.Missing)
+		}
+	}
+	return report
+}
+
+func buildHistoryReport(runs []*testing.HistoryRun, histories []*testing.TestHistory) string {
+	shown := 0
+	if len(histories) > 0 {
+		shown = len(histories[0].Statuses)
+	}
+	report := fmt.Sprintf(
+		"History of %s%v%s Tests in the last %s%v%s of %v Runs (oldest first):",
+		logging.AnsiBoldOn, len(histories), logging.AnsiAllDefault,
+		logging.AnsiBoldOn, shown, logging.AnsiAllDefault,
+		len(runs),
+	)
+	for _, testHistory := range histories {
+		report += "\n - "
+		for _, status := range testHistory.Statuses {
+			switch status {
+			case testing.HistoryPassed:
+				report += logging.AnsiFgGreen + "✓" + logging.AnsiAllDefault
+			case testing.HistoryFailed:
+				report += logging.AnsiFgLightRed + "✗" + logging.AnsiAllDefault
+			case testing.HistoryMissing:
+				report += logging.AnsiFgYellow + "?" + logging.AnsiAllDefault
+			default:
+				report += "·"
+			}
+		}
+		report += fmt.Sprintf(" %s%s%s", logging.AnsiFgBlue, testHistory.Name, logging.AnsiAllDefault)
+		if testHistory.Passed+testHistory.Failed > 0 {
+			report += fmt.Sprintf(" :: pass rate %.1f%% (%v/%v)",
+				testHistory.PassRate()*100,
+				testHistory.Passed,
+				testHistory.Passed+testHistory.Failed,
+			)
+		}
+		if testHistory.FailingSince != nil {
+			report += fmt.Sprintf(" :: %sfailing since %s%s",
+				logging.AnsiFgLightRed,
+				testHistory.FailingSince.StartTime.Local().Format(time.DateTime),
+				logging.AnsiAllDefault,
+			)
+		}
+	}
+	return report
+}
+
+func buildDiffReport(baseline string, diffs []*testing.TestDiff) string {
+	counts := make(map[testing.DiffStatus]int)
+	for _, testDiff := range diffs {
+		counts[testDiff.Status]++
+	}
+	report := fmt.Sprintf(
+		"Compared with %s: %s%v%s newly failing, %v still failing, %s%v%s newly passing, %v added, %v removed, %v newly ignored",
+		baseline,
+		logging.AnsiFgLightRed, counts[testing.DiffNewlyFailing], logging.AnsiAllDefault,
+		counts[testing.DiffStillFailing],
+		logging.AnsiFgGreen, counts[testing.DiffNewlyPassing], logging.AnsiAllDefault,
+		counts[testing.DiffAdded],
+		counts[testing.DiffRemoved],
+		counts[testing.DiffNewlyIgnored],
+	)
+	if counts[testing.DiffNoResult] > 0 {
+		report += fmt.Sprintf(", %s%v%s without result", logging.AnsiFgYellow, counts[testing.DiffNoResult], logging.AnsiAllDefault)
+	}
+	for _, testDiff := range diffs {
+		if testDiff.Status == testing.DiffUnchanged {
+			continue
+		}
+		var color string
+		switch testDiff.Status {
+		case testing.DiffNewlyFailing, testing.DiffStillFailing:
+			color = logging.AnsiFgLightRed
+		case testing.DiffNewlyPassing:
+			color = logging.AnsiFgGreen
+		case testing.DiffNoResult:
+			color = logging.AnsiFgYellow
+		default:
+			color = logging.AnsiAllDefault
+		}
+		report += fmt.Sprintf(
+			"\n - %s%s%s: %s%s%s",
+			color, testDiff.Status, logging.AnsiAllDefault,
+			logging.AnsiFgBlue, testDiff.Name, logging.AnsiAllDefault,
+		)
+	}
+	return report
+}
+
+func buildIgnorePatternReport(files []*testing.PdxTestFile, patterns []string) string {
+	report := "Ignore Patterns:"
+	for _, pattern := range patterns {
+		report += fmt.Sprintf("\n - %s%s%s: ", logging.AnsiBoldOn, pattern, logging.AnsiAllDefault)
+		matched := make([]string, 0)
+		for _, file := range files {
+			if file.IgnoredBy != pattern {
+				continue
+			}
+			matched = append(matched, fmt.Sprintf("%s (%s)", filepath.ToSlash(file.RelativePath), file.Origin()))
+		}
+		if len(matched) == 0 {
+			report += fmt.Sprintf("%sno matching files%s", logging.AnsiFgYellow, logging.AnsiAllDefault)
+		} else {
+			report += strings.Join(matched, ", ")
 		}
 	}
 	return report
@@ -229,6 +991,11 @@ func buildRunTestsReport(results *testing.ExecutionResults) string {
 				testResult.Test.Description,
 			)
 		}
+		if testResult.Test.AcceptableFailRate > 0 {
+			report += fmt.Sprintf(" :: tolerance %v%%",
+				testResult.Test.AcceptableFailRate*100,
+			)
+		}
 		if strings.TrimSpace(testResult.TestFile.DisplayName) != "" {
 			report += fmt.Sprintf(
 				" :: %s%s%s (%s)",
@@ -0,0 +1,205 @@
+package reporting
+
+import (
+	_ "embed"
+	"fmt"
+	"html/template"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"bahmut.de/pdx-test-runner/game"
+	"bahmut.de/pdx-test-runner/testing"
+)
+
+const htmlReportFileName = "report.html"
+
+//go:embed report.html
+var htmlReportTemplate string
+
+// Data of the html report template
+type htmlReport struct {
+	StartTime  string
+	EndTime    string
+	Duration   string
+	Game       string
+	Outcome    string
+	Completed  bool
+	Successes  int
+	Failures   int
+	Missing    int
+	Ignored    int
+	ResultFile string   // Name of the raw result file in the output directory (empty if it does not exist)
+	SaveGames  []string // Save games not matching any test
+	Baseline   string
+	Diff       []*testing.TestDiff // Changed tests only
+	Origins    []*htmlOrigin
+}
+
+type htmlOrigin struct {
+	Name     string
+	Failures int
+	Files    []*htmlFile
+}
+
+type htmlFile struct {
+	Id           int
+	DisplayName  string
+	RelativePath string
+	Origin       string
+	Ignored      bool
+	Overrides    string
+	Successes    int
+	Failures     int
+	Tests        []*htmlTest
+}
+
+type htmlTest struct {
+	Name        string
+	DisplayName string
+	Description string
+	Line        int
+	Status      string // passed, failed, missing or ignored
+	Symbol      string
+	Date        string
+	Tolerance   string
+	Checks      string
+	SaveGames   []string
+	SearchText  string
+}
+
+// WriteHTMLReport writes a self-contained html report with the tests grouped by origin and test file.
+// It links the collected save games and the raw test result file in the output directory.
+func WriteHTMLReport(results *testing.ExecutionResults, testFiles []*testing.PdxTestFile, settings *game.LauncherSettings) error {
+	report := &htmlReport{
+		StartTime: results.StartTime.Format(time.DateTime),
+		EndTime:   results.EndTime.Format(time.DateTime),
+		Duration:  results.Duration.String(),
+		Game:      gameName(settings.GameType),
+		Outcome:   results.Outcome.String(),
+		Completed: results.Outcome == testing.OutcomeCompleted,
+		Baseline:  filepath.ToSlash(results.Baseline),
+		Origins:   make([]*htmlOrigin, 0),
+	}
+	if _, err := os.Stat(filepath.Join(results.OutputDirectory, testing.ResultFileName)); err == nil {
+		report.ResultFile = testing.ResultFileName
+	}
+	for _, testDiff := range results.Diff {
+		if testDiff.Status != testing.DiffUnchanged {
+			report.Diff = append(report.Diff, testDiff)
+		}
+	}
+
+	found := make(map[*testing.PdxTest]*testing.TestResult)
+	for _, result := range results.TestResults {
+		found[result.Test] = result
+	}
+	saveGames, unassigned := assignSaveGames(testFiles, results.SaveGames)
+	report.SaveGames = unassigned
+	origins := make(map[string]*htmlOrigin)
+	for index, file := range testFiles {
+		origin, ok := origins[file.Origin()]
+		if !ok {
+			origin = &htmlOrigin{Name: file.Origin(), Files: make([]*htmlFile, 0)}
+			origins[file.Origin()] = origin
+			report.Origins = append(report.Origins, origin)
+		}
+		htmlFile := &htmlFile{
+			Id:           index,
+			DisplayName:  file.DisplayName,
+			RelativePath: filepath.ToSlash(file.RelativePath),
+			Origin:       file.Origin(),
+			Ignored:      file.Ignored,
+			Tests:        make([]*htmlTest, 0, len(file.Tests)),
+		}
+		chain := make([]string, 0)
+		for _, overridden := range file.OverrideChain() {
+			chain = append(chain, overridden.Origin())
+		}
+		htmlFile.Overrides = strings.Join(chain, " → ")
+
+		for _, test := range file.Tests {
+			htmlTest := &htmlTest{
+				Name:        test.Name,
+				DisplayName: test.DisplayName,
+				Description: test.Description,
+				Line:        test.Position.Line,
+				Tolerance:   formatTolerance(test.AcceptableFailRate),
+				Checks:      compactSource(test.Success),
+				SaveGames:   saveGames[test],
+			}
+			result, ok := found[test]
+			switch {
+			case ok && result.Success:
+				htmlTest.Status, htmlTest.Symbol = "passed", "✅"
+				htmlFile.Successes++
+				report.Successes++
+			case ok:
+				htmlTest.Status, htmlTest.Symbol = "failed", "❌"
+				htmlFile.Failures++
+				origin.Failures++
+				report.Failures++
+			case file.IsTestActive(test):
+				htmlTest.Status, htmlTest.Symbol = "missing", "❔"
+				report.Missing++
+			default:
+				htmlTest.Status, htmlTest.Symbol = "ignored", "➖"
+				report.Ignored++
+			}
+			if ok {
+				htmlTest.Date = result.Date
+			}
+			htmlTest.SearchText = strings.ToLower(strings.Join([]string{
+				test.Name, test.DisplayName, test.Description, file.Name, file.DisplayName,
+			}, " "))
+			htmlFile.Tests = append(htmlFile.Tests, htmlTest)
+		}
+		origin.Files = append(origin.Files, htmlFile)
+	}
+
+	reportTemplate, err := template.New(htmlReportFileName).Parse(htmlReportTemplate)
+	if err != nil {
+		return fmt.Errorf("error parsing html report template: %v", err)
+	}
+	builder := strings.Builder{}
+	err = reportTemplate.Execute(&builder, report)
+	if err != nil {
+		return fmt.Errorf("error generating html report: %v", err)
+	}
+
+	reportFile := filepath.Join(results.OutputDirectory, htmlReportFileName)
+	err = os.WriteFile(reportFile, []byte(builder.String()), os.ModePerm)
+	if err != nil {
+		return fmt.Errorf("error writing html report: %v", err)
+	}
+
+	return nil
+}
+
+// assignSaveGames assigns every collected test fail save game to the test with the longest matching name,
+// since save game names start with the name of the failed test.
+// It also returns the save games not matching any test.
+func assignSaveGames(testFiles []*testing.PdxTestFile, saveGames []string) (map[*testing.PdxTest][]string, []string) {
+	assigned := make(map[*testing.PdxTest][]string)
+	unassigned := make([]string, 0)
+	for _, saveGame := range saveGames {
+		var match *testing.PdxTest
+		for _, file := range testFiles {
+			for _, test := range file.Tests {
+				if !strings.HasPrefix(saveGame, testing.FailTestPrefix+test.Name) {
+					continue
+				}
+				if match == nil || len(test.Name) > len(match.Name) {
+					match = test
+				}
+			}
+		}
+		if match == nil {
+			unassigned = append(unassigned, saveGame)
+			continue
+		}
+		assigned[match] = append(assigned[match], saveGame)
+	}
+	return assigned, unassigned
+}
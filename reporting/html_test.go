@@ -0,0 +1,26 @@
+package reporting
+
+import (
+	"slices"
+	gotesting "testing"
+
+	"bahmut.de/pdx-test-runner/testing"
+)
+
+func TestAssignSaveGames(t *gotesting.T) {
+	short := &testing.PdxTest{Name: "test_a"}
+	long := &testing.PdxTest{Name: "test_a_b"}
+	testFiles := []*testing.PdxTestFile{{Name: "a.txt", Tests: []*testing.PdxTest{short, long}}}
+	saveGames := []string{"TEST_FAIL_test_a_1.v3", "TEST_FAIL_test_a_b_1.v3", "TEST_FAIL_unknown_1.v3"}
+
+	assigned, unassigned := assignSaveGames(testFiles, saveGames)
+	if !slices.Equal(assigned[short], []string{"TEST_FAIL_test_a_1.v3"}) {
+		t.Errorf("unexpected save games of %s: %v", short.Name, assigned[short])
+	}
+	if !slices.Equal(assigned[long], []string{"TEST_FAIL_test_a_b_1.v3"}) {
+		t.Errorf("unexpected save games of %s: %v", long.Name, assigned[long])
+	}
+	if !slices.Equal(unassigned, []string{"TEST_FAIL_unknown_1.v3"}) {
+		t.Errorf("unexpected unassigned save games: %v", unassigned)
+	}
+}
@@ -0,0 +1,218 @@
+package reporting
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"bahmut.de/pdx-test-runner/game"
+	"bahmut.de/pdx-test-runner/testing"
+)
+
+// JSONSchemaVersion is increased whenever the structure of the json report changes incompatibly.
+// Adding new optional attributes does not change the version.
+const JSONSchemaVersion = 1
+
+const jsonReportFileName = "results.json"
+
+// JSONReport is the machine-readable export of a test run.
+// The schema is documented in the README and must stay stable across releases.
+type JSONReport struct {
+	SchemaVersion   int               `json:"schema-version"`
+	GameId          string            `json:"game-id"`
+	GameName        string            `json:"game-name"`
+	Outcome         string            `json:"outcome"`
+	StartTime       time.Time         `json:"start-time"`
+	EndTime         time.Time         `json:"end-time"`
+	DurationSeconds float64           `json:"duration-seconds"`
+	OutputDirectory string            `json:"output-directory"`
+	Settings        *JSONSettings     `json:"settings"`
+	TestFiles       []*JSONTestFile   `json:"test-files"`
+	TestResults     []*JSONTestResult `json:"test-results"`
+	SaveGames       []string          `json:"save-games"`
+}
+
+type JSONSettings struct {
+	DataPath    string `json:"data-path"`
+	ExecPath    string `json:"exec-path"`
+	ContentPath string `json:"content-path"`
+}
+
+type JSONTestFile struct {
+	Name         string          `json:"name"`
+	DisplayName  string          `json:"display-name"`
+	Path         string          `json:"path"`
+	Root         string          `json:"root"`
+	RelativePath string          `json:"relative-path"`
+	Origin       string          `json:"origin"`
+	Mod          string          `json:"mod"`
+	ModName      string          `json:"mod-name"`
+	Ignored      bool            `json:"ignored"`
+	LastDate     string          `json:"last-date"`
+	Tests        []*JSONTest     `json:"tests"`
+	Overrides    []*JSONOverride `json:"overrides"`
+}
+
+// JSONOverride is a test file replaced by a file with the same relative path from a later mod
+type JSONOverride struct {
+	Path   string `json:"path"`
+	Origin string `json:"origin"`
+}
+
+type JSONTest struct {
+	Ignored            bool    `json:"ignored"`
+	Name               string  `json:"name"`
+	DisplayName        string  `json:"display-name"`
+	Description        string  `json:"description"`
+	Line               int     `json:"line"`
+	AcceptableFailRate float64 `json:"acceptable-fail-rate"`
+	Success            string  `json:"success"`
+	Fail               string  `json:"fail"`
+}
+
+type JSONTestResult struct {
+	Test    string `json:"test"`
+	File    string `json:"file"`
+	Success bool   `json:"success"`
+	Date    string `json:"date"`
+}
+
+// WriteJSONReport writes the test run as a versioned json document for post-processing.
+func WriteJSONReport(results *testing.ExecutionResults, testFiles []*testing.PdxTestFile, settings *game.LauncherSettings) error {
+	report := &JSONReport{
+		SchemaVersion:   JSONSchemaVersion,
+		GameId:          settings.GameId,
+		GameName:        gameName(settings.GameType),
+		Outcome:         results.Outcome.String(),
+		StartTime:       results.StartTime,
+		EndTime:         results.EndTime,
+		DurationSeconds: results.Duration.Seconds(),
+		OutputDirectory: results.OutputDirectory,
+		Settings: &JSONSettings{
+			DataPath:    settings.DataPath,
+			ExecPath:    settings.ExecPath,
+			ContentPath: settings.ContentPath,
+		},
+		TestFiles:   make([]*JSONTestFile, 0, len(testFiles)),
+		TestResults: make([]*JSONTestResult, 0, len(results.TestResults)),
+		SaveGames:   results.SaveGames,
+	}
+	if report.SaveGames == nil {
+		report.SaveGames = make([]string, 0)
+	}
+	for _, file := range testFiles {
+		jsonFile := &JSONTestFile{
+			Name:         file.Name,
+			DisplayName:  file.DisplayName,
+			Path:         file.Path,
+			Root:         file.Root,
+			RelativePath: filepath.ToSlash(file.RelativePath),
+			Origin:       file.Origin(),
+			Mod:          file.Mod,
+			ModName:      file.ModName,
+			Ignored:      file.Ignored,
+			LastDate:     file.LastDate,
+			Tests:        make([]*JSONTest, 0, len(file.Tests)),
+			Overrides:    make([]*JSONOverride, 0),
+		}
+		for _, overridden := range file.OverrideChain() {
+			jsonFile.Overrides = append(jsonFile.Overrides, &JSONOverride{
+				Path:   overridden.Path,
+				Origin: overridden.Origin(),
+			})
+		}
+		for _, test := range file.Tests {
+			jsonFile.Tests = append(jsonFile.Tests, &JSONTest{
+				Ignored:            !file.IsTestActive(test),
+				Name:               test.Name,
+				DisplayName:        test.DisplayName,
+				Description:        test.Description,
+				Line:               test.Position.Line,
+				AcceptableFailRate: test.AcceptableFailRate,
+				Success:            test.Success,
+				Fail:               test.Fail,
+			})
+		}
+		report.TestFiles = append(report.TestFiles, jsonFile)
+	}
+	for _, result := range results.TestResults {
+		report.TestResults = append(report.TestResults, &JSONTestResult{
+			Test:    result.Test.Name,
+			File:    result.TestFile.Name,
+			Success: result.Success,
+			Date:    result.Date,
+		})
+	}
+
+	content, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error encoding json report: %v", err)
+	}
+
+	reportFile := filepath.Join(results.OutputDirectory, jsonReportFileName)
+	err = os.WriteFile(reportFile, content, os.ModePerm)
+	if err != nil {
+		return fmt.Errorf("error writing json report: %v", err)
+	}
+
+	return nil
+}
+
+// ReadJSONReport reads the json report from the output directory of a test run.
+// The returned error wraps os.ErrNotExist if the test run has no json report.
+func ReadJSONReport(directory string) (*JSONReport, error) {
+	reportFile := filepath.Join(directory, jsonReportFileName)
+	content, err := os.ReadFile(reportFile)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("json report does not exist (%s): %w", reportFile, err)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading json report (%s): %v", reportFile, err)
+	}
+	report := &JSONReport{}
+	err = json.Unmarshal(content, report)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing json report (%s): %v", reportFile, err)
+	}
+	if report.SchemaVersion != JSONSchemaVersion {
+		return nil, fmt.Errorf("unsupported json report schema version %v: %s", report.SchemaVersion, reportFile)
+	}
+	return report, nil
+}
+
+// HistoryRun returns the status of every test in the reported test run
+func (report *JSONReport) HistoryRun() *testing.HistoryRun {
+	results := make(map[string]bool)
+	for _, result := range report.TestResults {
+		results[result.Test] = result.Success
+	}
+	run := &testing.HistoryRun{
+		StartTime:       report.StartTime,
+		EndTime:         report.EndTime,
+		Outcome:         report.Outcome,
+		OutputDirectory: report.OutputDirectory,
+		Tests:           make([]*testing.HistoryTest, 0),
+	}
+	for _, file := range report.TestFiles {
+		for _, test := range file.Tests {
+			status := testing.HistoryMissing
+			if success, ok := results[test.Name]; ok && success {
+				status = testing.HistoryPassed
+			} else if ok {
+				status = testing.HistoryFailed
+			} else if test.Ignored {
+				status = testing.HistoryIgnored
+			}
+			run.Tests = append(run.Tests, &testing.HistoryTest{
+				Name:   test.Name,
+				File:   file.RelativePath,
+				Origin: file.Origin,
+				Status: status,
+			})
+		}
+	}
+	return run
+}
@@ -0,0 +1,65 @@
+package reporting
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	gotesting "testing"
+
+	"bahmut.de/pdx-test-runner/game"
+	"bahmut.de/pdx-test-runner/testing"
+)
+
+func TestWriteJSONReport(t *gotesting.T) {
+	passed := &testing.PdxTest{Name: "test_passed"}
+	missing := &testing.PdxTest{Name: "test_missing"}
+	unselected := &testing.PdxTest{Name: "test_unselected", Ignored: true}
+	economy := &testing.PdxTestFile{Name: "economy.txt", Tests: []*testing.PdxTest{passed, missing, unselected}}
+	ignored := &testing.PdxTestFile{Name: "war.txt.ignore", Ignored: true, Tests: []*testing.PdxTest{{Name: "test_war"}}}
+	results := &testing.ExecutionResults{
+		Outcome:         testing.OutcomeCompleted,
+		OutputDirectory: t.TempDir(),
+		TestResults:     []*testing.TestResult{{Success: true, Date: "1836.2.1", Test: passed, TestFile: economy}},
+	}
+
+	err := WriteJSONReport(results, []*testing.PdxTestFile{economy, ignored}, &game.LauncherSettings{GameType: game.Victoria3})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	content, err := os.ReadFile(filepath.Join(results.OutputDirectory, jsonReportFileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The attribute names are part of the documented schema
+	var raw map[string]any
+	err = json.Unmarshal(content, &raw)
+	if err != nil {
+		t.Fatalf("invalid json report: %v", err)
+	}
+	if version, ok := raw["schema-version"].(float64); !ok || int(version) != JSONSchemaVersion {
+		t.Errorf("unexpected schema-version: %v", raw["schema-version"])
+	}
+	if saveGames, ok := raw["save-games"].([]any); !ok || len(saveGames) != 0 {
+		t.Errorf("save-games must be an empty list: %v", raw["save-games"])
+	}
+
+	report := &JSONReport{}
+	err = json.Unmarshal(content, report)
+	if err != nil {
+		t.Fatalf("invalid json report: %v", err)
+	}
+	if report.Outcome != "Completed" || len(report.TestFiles) != 2 {
+		t.Fatalf("unexpected report: %v, %v test files", report.Outcome, len(report.TestFiles))
+	}
+	tests := report.TestFiles[0].Tests
+	if len(tests) != 3 || tests[0].Ignored || tests[1].Ignored || !tests[2].Ignored {
+		t.Errorf("only the unselected test must be ignored")
+	}
+	if !report.TestFiles[1].Ignored || !report.TestFiles[1].Tests[0].Ignored {
+		t.Errorf("tests of ignored files must be ignored")
+	}
+	if len(report.TestResults) != 1 || report.TestResults[0].Test != "test_passed" || !report.TestResults[0].Success {
+		t.Errorf("unexpected test results: %v", report.TestResults)
+	}
+}
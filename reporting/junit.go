@@ -0,0 +1,147 @@
+package reporting
+
+import (
+	"encoding/xml"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"bahmut.de/pdx-test-runner/game"
+	"bahmut.de/pdx-test-runner/testing"
+)
+
+const junitReportFileName = "junit.xml"
+
+type junitTestSuites struct {
+	XMLName  xml.Name          `xml:"testsuites"`
+	Name     string            `xml:"name,attr"`
+	Tests    int               `xml:"tests,attr"`
+	Failures int               `xml:"failures,attr"`
+	Errors   int               `xml:"errors,attr"`
+	Skipped  int               `xml:"skipped,attr"`
+	Time     string            `xml:"time,attr"`
+	Suites   []*junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name       string           `xml:"name,attr"`
+	Tests      int              `xml:"tests,attr"`
+	Failures   int              `xml:"failures,attr"`
+	Errors     int              `xml:"errors,attr"`
+	Skipped    int              `xml:"skipped,attr"`
+	Timestamp  string           `xml:"timestamp,attr"`
+	Properties []*junitProperty `xml:"properties>property,omitempty"`
+	TestCases  []*junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name       string           `xml:"name,attr"`
+	ClassName  string           `xml:"classname,attr"`
+	Properties []*junitProperty `xml:"properties>property,omitempty"`
+	Failure    *junitMessage    `xml:"failure,omitempty"`
+	Error      *junitMessage    `xml:"error,omitempty"`
+	Skipped    *junitMessage    `xml:"skipped,omitempty"`
+	SystemOut  string           `xml:"system-out,omitempty"`
+}
+
+type junitProperty struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value,attr"`
+}
+
+type junitMessage struct {
+	Message string `xml:"message,attr"`
+}
+
+// WriteJUnitReport writes the test results as JUnit XML,
+// so they can be displayed natively by CI servers.
+// Every test file is mapped to a test suite and every test to a test case.
+func WriteJUnitReport(results *testing.ExecutionResults, testFiles []*testing.PdxTestFile, settings *game.LauncherSettings) error {
+	resultsByTest := make(map[*testing.PdxTest]*testing.TestResult)
+	for _, result := range results.TestResults {
+		resultsByTest[result.Test] = result
+	}
+
+	suites := &junitTestSuites{
+		Name: fmt.Sprintf("%s Scripted Tests", gameName(settings.GameType)),
+		Time: fmt.Sprintf("%.3f", results.Duration.Seconds()),
+	}
+	for _, file := range testFiles {
+		suite := &junitTestSuite{
+			Name:      file.Name,
+			Timestamp: results.StartTime.Format(time.RFC3339),
+		}
+		if file.DisplayName != "" {
+			suite.Properties = append(suite.Properties, &junitProperty{Name: "display-name", Value: file.DisplayName})
+		}
+		if file.LastDate != "" {
+			suite.Properties = append(suite.Properties, &junitProperty{Name: "last-date", Value: file.LastDate})
+		}
+		for _, test := range file.Tests {
+			testCase := &junitTestCase{
+				Name:      test.Name,
+				ClassName: file.Name,
+			}
+			if test.DisplayName != "" {
+				testCase.Properties = append(testCase.Properties, &junitProperty{Name: "display-name", Value: test.DisplayName})
+			}
+			if test.Description != "" {
+				testCase.Properties = append(testCase.Properties, &junitProperty{Name: "description", Value: test.Description})
+			}
+			testCase.Properties = append(testCase.Properties, &junitProperty{Name: "acceptable-fail-rate", Value: formatTolerance(test.AcceptableFailRate)})
+			if test.Success != "" {
+				testCase.Properties = append(testCase.Properties, &junitProperty{Name: "success", Value: test.Success})
+			}
+			if test.Fail != "" {
+				testCase.Properties = append(testCase.Properties, &junitProperty{Name: "fail", Value: test.Fail})
+			}
+			testCase.Properties = append(testCase.Properties, &junitProperty{Name: "source", Value: fmt.Sprintf("%s:%d", file.RelativePath, test.Position.Line)})
+
+			result := resultsByTest[test]
+			switch {
+			case file.Ignored:
+				testCase.Skipped = &junitMessage{Message: "Test file is ignored"}
+				suite.Skipped++
+			case test.Ignored:
+				testCase.Skipped = &junitMessage{Message: "Test is not selected"}
+				suite.Skipped++
+			case result == nil:
+				message := "No test result found"
+				if results.Outcome != testing.OutcomeCompleted {
+					message += fmt.Sprintf(" (test run outcome: %s)", results.Outcome)
+				}
+				testCase.Error = &junitMessage{Message: message}
+				suite.Errors++
+			case !result.Success:
+				testCase.Properties = append(testCase.Properties, &junitProperty{Name: "date", Value: result.Date})
+				testCase.Failure = &junitMessage{Message: fmt.Sprintf("Test failed at in-game date %s", result.Date)}
+				testCase.SystemOut = fmt.Sprintf("Failed at in-game date: %s", result.Date)
+				suite.Failures++
+			default:
+				testCase.Properties = append(testCase.Properties, &junitProperty{Name: "date", Value: result.Date})
+				testCase.SystemOut = fmt.Sprintf("Succeeded at in-game date: %s", result.Date)
+			}
+			suite.TestCases = append(suite.TestCases, testCase)
+			suite.Tests++
+		}
+		suites.Tests += suite.Tests
+		suites.Failures += suite.Failures
+		suites.Errors += suite.Errors
+		suites.Skipped += suite.Skipped
+		suites.Suites = append(suites.Suites, suite)
+	}
+
+	content, err := xml.MarshalIndent(suites, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error encoding junit report: %v", err)
+	}
+
+	reportFile := filepath.Join(results.OutputDirectory, junitReportFileName)
+	err = os.WriteFile(reportFile, append([]byte(xml.Header), content...), os.ModePerm)
+	if err != nil {
+		return fmt.Errorf("error writing junit report: %v", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,65 @@
+package reporting
+
+import (
+	"encoding/xml"
+	"os"
+	"path/filepath"
+	gotesting "testing"
+
+	"bahmut.de/pdx-test-runner/game"
+	"bahmut.de/pdx-test-runner/testing"
+)
+
+func TestWriteJUnitReport(t *gotesting.T) {
+	passed := &testing.PdxTest{Name: "test_passed"}
+	failed := &testing.PdxTest{Name: "test_failed"}
+	missing := &testing.PdxTest{Name: "test_missing"}
+	unselected := &testing.PdxTest{Name: "test_unselected", Ignored: true}
+	economy := &testing.PdxTestFile{Name: "economy.txt", Tests: []*testing.PdxTest{passed, failed, missing, unselected}}
+	ignored := &testing.PdxTestFile{Name: "war.txt.ignore", Ignored: true, Tests: []*testing.PdxTest{{Name: "test_war"}}}
+	results := &testing.ExecutionResults{
+		Outcome:         testing.OutcomeStalled,
+		OutputDirectory: t.TempDir(),
+		TestResults: []*testing.TestResult{
+			{Success: true, Date: "1836.2.1", Test: passed, TestFile: economy},
+			{Success: false, Date: "1836.3.1", Test: failed, TestFile: economy},
+		},
+	}
+
+	err := WriteJUnitReport(results, []*testing.PdxTestFile{economy, ignored}, &game.LauncherSettings{GameType: game.Victoria3})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	content, err := os.ReadFile(filepath.Join(results.OutputDirectory, junitReportFileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	report := &junitTestSuites{}
+	err = xml.Unmarshal(content, report)
+	if err != nil {
+		t.Fatalf("invalid junit report: %v", err)
+	}
+
+	if report.Tests != 5 || report.Failures != 1 || report.Errors != 1 || report.Skipped != 2 {
+		t.Errorf("unexpected totals: %v tests, %v failures, %v errors, %v skipped", report.Tests, report.Failures, report.Errors, report.Skipped)
+	}
+	if len(report.Suites) != 2 || len(report.Suites[0].TestCases) != 4 || len(report.Suites[1].TestCases) != 1 {
+		t.Fatalf("unexpected test suites")
+	}
+	testCases := report.Suites[0].TestCases
+	if testCases[0].Failure != nil || testCases[0].Error != nil || testCases[0].Skipped != nil {
+		t.Errorf("passed test must not be reported as failure, error or skipped")
+	}
+	if testCases[1].Failure == nil || testCases[1].Failure.Message != "Test failed at in-game date 1836.3.1" {
+		t.Errorf("unexpected failure: %v", testCases[1].Failure)
+	}
+	if testCases[2].Error == nil || testCases[2].Error.Message != "No test result found (test run outcome: Stalled)" {
+		t.Errorf("missing test must be reported as error: %v", testCases[2].Error)
+	}
+	if testCases[3].Skipped == nil || testCases[3].Skipped.Message != "Test is not selected" {
+		t.Errorf("unselected test must be skipped: %v", testCases[3].Skipped)
+	}
+	if skipped := report.Suites[1].TestCases[0].Skipped; skipped == nil || skipped.Message != "Test file is ignored" {
+		t.Errorf("test of ignored file must be skipped: %v", skipped)
+	}
+}
@@ -0,0 +1,189 @@
+package reporting
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"bahmut.de/pdx-test-runner/game"
+	"bahmut.de/pdx-test-runner/testing"
+)
+
+// MatrixRun is the test run of a single matrix configuration.
+// Results and TestFiles are nil if the run failed before the game was started.
+type MatrixRun struct {
+	Name           string
+	ModDirectories []string
+	Results        *testing.ExecutionResults
+	TestFiles      []*testing.PdxTestFile
+}
+
+// Status of a test in a single matrix run
+const (
+	matrixStatusSuccess = "✅"
+	matrixStatusFailure = "❌"
+	matrixStatusMissing = "❔" // Active, but without result
+	matrixStatusSkipped = "➖" // Ignored or not defined in the configuration
+)
+
+// WriteMatrixReport writes a report comparing the test results of all matrix configurations
+// into a new directory inside the output directory. It returns the path of the report.
+func WriteMatrixReport(outputDirectory string, startTime time.Time, runs []*MatrixRun, settings *game.LauncherSettings) (string, error) {
+	builder := strings.Builder{}
+
+	builder.WriteString("# Matrix Test Run - ")
+	builder.WriteString(startTime.Format(time.DateTime))
+	builder.WriteString("\n")
+	builder.WriteString("\n")
+	builder.WriteString("**Game:** ")
+	builder.WriteString(gameName(settings.GameType))
+	builder.WriteString("\n")
+	builder.WriteString("\n")
+	builder.WriteString("## Configurations\n\n")
+	builder.WriteString("| Configuration | Outcome | Successful | Failed | Missing | Mods | Output |\n")
+	builder.WriteString("|---|---|---|---|---|---|---|\n")
+	for _, run := range runs {
+		builder.WriteString("| ")
+		builder.WriteString(run.Name)
+		builder.WriteString(" | ")
+		if run.Results == nil {
+			builder.WriteString("Error | - | - | - | ")
+			builder.WriteString(matrixMods(run.ModDirectories))
+			builder.WriteString(" | - |\n")
+			continue
+		}
+		successes, failures := countResults(run.Results)
+		builder.WriteString(run.Results.Outcome.String())
+		builder.WriteString(" | ")
+		builder.WriteString(strconv.Itoa(successes))
+		builder.WriteString(" | ")
+		builder.WriteString(strconv.Itoa(failures))
+		builder.WriteString(" | ")
+		builder.WriteString(strconv.Itoa(len(testing.MissingTests(run.Results, run.TestFiles))))
+		builder.WriteString(" | ")
+		builder.WriteString(matrixMods(run.ModDirectories))
+		builder.WriteString(" | ")
+		builder.WriteString(filepath.ToSlash(run.Results.OutputDirectory))
+		builder.WriteString(" |\n")
+	}
+	builder.WriteString("\n")
+
+	names, statuses := matrixStatuses(runs)
+	builder.WriteString("## Test Results\n\n")
+	builder.WriteString("Tests with different results across configurations are marked with ⚠️.\n\n")
+	builder.WriteString("| Test |")
+	for _, run := range runs {
+		builder.WriteString(" ")
+		builder.WriteString(run.Name)
+		builder.WriteString(" |")
+	}
+	builder.WriteString("\n|---|")
+	builder.WriteString(strings.Repeat("---|", len(runs)))
+	builder.WriteString("\n")
+	for _, name := range names {
+		builder.WriteString("| ")
+		builder.WriteString(name)
+		if differs(statuses[name]) {
+			builder.WriteString(" ⚠️")
+		}
+		builder.WriteString(" |")
+		for _, status := range statuses[name] {
+			builder.WriteString(" ")
+			builder.WriteString(status)
+			builder.WriteString(" |")
+		}
+		builder.WriteString("\n")
+	}
+
+	reportDirectory := filepath.Join(outputDirectory, startTime.Format("2006-01-02_15_04_05"))
+	err := os.MkdirAll(reportDirectory, os.ModePerm)
+	if err != nil {
+		return "", fmt.Errorf("error creating matrix report directory: %v", err)
+	}
+	reportFile := filepath.Join(reportDirectory, "matrix.md")
+	err = os.WriteFile(reportFile, []byte(builder.String()), os.ModePerm)
+	if err != nil {
+		return "", fmt.Errorf("error writing matrix report: %v", err)
+	}
+	return reportFile, nil
+}
+
+// matrixStatuses returns the names of all tests in order of appearance
+// and the status of every test in each run
+func matrixStatuses(runs []*MatrixRun) ([]string, map[string][]string) {
+	names := make([]string, 0)
+	statuses := make(map[string][]string)
+	for index, run := range runs {
+		if run.Results == nil {
+			continue
+		}
+		results := make(map[*testing.PdxTest]*testing.TestResult)
+		for _, result := range run.Results.TestResults {
+			results[result.Test] = result
+		}
+		for _, file := range run.TestFiles {
+			for _, test := range file.Tests {
+				if _, ok := statuses[test.Name]; !ok {
+					names = append(names, test.Name)
+					statuses[test.Name] = make([]string, len(runs))
+					for i := range runs {
+						statuses[test.Name][i] = matrixStatusSkipped
+					}
+				}
+				result, found := results[test]
+				switch {
+				case found && result.Success:
+					statuses[test.Name][index] = matrixStatusSuccess
+				case found:
+					statuses[test.Name][index] = matrixStatusFailure
+				case file.IsTestActive(test):
+					statuses[test.Name][index] = matrixStatusMissing
+				}
+			}
+		}
+	}
+	return names, statuses
+}
+
+// differs reports whether a test that ran in multiple configurations had different results
+func differs(statuses []string) bool {
+	first := ""
+	for _, status := range statuses {
+		if status == matrixStatusSkipped {
+			continue
+		}
+		if first == "" {
+			first = status
+		} else if status != first {
+			return true
+		}
+	}
+	return false
+}
+
+func countResults(results *testing.ExecutionResults) (int, int) {
+	successes := 0
+	failures := 0
+	for _, result := range results.TestResults {
+		if result.Success {
+			successes++
+		} else {
+			failures++
+		}
+	}
+	return successes, failures
+}
+
+func matrixMods(modDirectories []string) string {
+	if len(modDirectories) == 0 {
+		return "none"
+	}
+	mods := make([]string, 0, len(modDirectories))
+	for _, directory := range modDirectories {
+		mods = append(mods, filepath.Base(directory))
+	}
+	return strings.Join(mods, ", ")
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 
@@ -11,6 +12,46 @@ import (
 	"bahmut.de/pdx-test-runner/testing"
 )
 
+const (
+	FormatMarkdown = "markdown"
+	FormatJUnit    = "junit"
+	FormatJSON     = "json"
+	FormatHTML     = "html"
+)
+
+// Report writers by format name
+var reportWriters = map[string]func(*testing.ExecutionResults, []*testing.PdxTestFile, *game.LauncherSettings) error{
+	FormatMarkdown: WriteReport,
+	FormatJUnit:    WriteJUnitReport,
+	FormatJSON:     WriteJSONReport,
+	FormatHTML:     WriteHTMLReport,
+}
+
+// ValidateFormats checks that a report writer exists for every given format.
+func ValidateFormats(formats []string) error {
+	for _, format := range formats {
+		if _, ok := reportWriters[format]; !ok {
+			return fmt.Errorf("unsupported report format: %s", format)
+		}
+	}
+	return nil
+}
+
+// WriteReports writes a report for every given format into the output directory of the test run.
+func WriteReports(formats []string, results *testing.ExecutionResults, testFiles []*testing.PdxTestFile, settings *game.LauncherSettings) error {
+	err := ValidateFormats(formats)
+	if err != nil {
+		return err
+	}
+	for _, format := range formats {
+		err = reportWriters[format](results, testFiles, settings)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func WriteReport(results *testing.ExecutionResults, testFiles []*testing.PdxTestFile, settings *game.LauncherSettings) error {
 	builder := strings.Builder{}
 
@@ -18,18 +59,22 @@ func WriteReport(results *testing.ExecutionResults, testFiles []*testing.PdxTest
 	builder.WriteString(results.StartTime.Format(time.DateTime))
 	builder.WriteString("\n")
 	builder.WriteString("\n")
+	if results.Outcome != testing.OutcomeCompleted {
+		builder.WriteString("> **Note:** The test run did not complete (")
+		builder.WriteString(results.Outcome.String())
+		builder.WriteString("), the results are partial.\n")
+		builder.WriteString("\n")
+	}
 	builder.WriteString("## General")
 	builder.WriteString("\n")
 	builder.WriteString("\n")
 	builder.WriteString("**Game:** ")
-	switch settings.GameType {
-	case game.Victoria3:
-		builder.WriteString("Victoria 3\n")
-	case game.CrusaderKings3:
-		builder.WriteString("Crusader Kings 3\n")
-	default:
-		builder.WriteString("Unknown\n")
-	}
+	builder.WriteString(gameName(settings.GameType))
+	builder.WriteString("\n")
+	builder.WriteString("\n")
+	builder.WriteString("**Outcome:** ")
+	builder.WriteString(results.Outcome.String())
+	builder.WriteString("\n")
 	builder.WriteString("\n")
 	builder.WriteString("**Start Time:** ")
 	builder.WriteString(results.StartTime.Format(time.DateTime))
@@ -44,15 +89,15 @@ func WriteReport(results *testing.ExecutionResults, testFiles []*testing.PdxTest
 	builder.WriteString("\n")
 	builder.WriteString("\n")
 	builder.WriteString("## Found Test Files & Tests\n\n")
-	builder.WriteString("| Active | Test | Description | File |\n")
-	builder.WriteString("|---|---|---|---|\n")
+	builder.WriteString("| Active | Test | Description | File | Origin |\n")
+	builder.WriteString("|---|---|---|---|---|\n")
 	for _, file := range testFiles {
 		for _, test := range file.Tests {
 			builder.WriteString("| ")
-			if file.Ignored {
-				builder.WriteString("❌")
-			} else {
+			if file.IsTestActive(test) {
 				builder.WriteString("✅")
+			} else {
+				builder.WriteString("❌")
 			}
 			builder.WriteString(" | ")
 			if test.DisplayName != "" {
@@ -78,13 +123,18 @@ func WriteReport(results *testing.ExecutionResults, testFiles []*testing.PdxTest
 			} else {
 				builder.WriteString(file.Name)
 			}
+			builder.WriteString(" | ")
+			builder.WriteString(file.Origin())
 			builder.WriteString(" |\n")
 		}
 	}
 	builder.WriteString("\n")
+	writeOverrides(&builder, testFiles)
+	writeDuplicates(&builder, testFiles)
+	writeDiff(&builder, results)
 	builder.WriteString("## Test Results\n\n")
-	builder.WriteString("| Success | Test | Date | Description | File |\n")
-	builder.WriteString("|---|---|---|---|---|\n")
+	builder.WriteString("| Success | Test | Date | Tolerance | Checks | Description | File | Origin |\n")
+	builder.WriteString("|---|---|---|---|---|---|---|---|\n")
 	for _, result := range results.TestResults {
 		builder.WriteString("| ")
 		if result.Success {
@@ -104,6 +154,16 @@ func WriteReport(results *testing.ExecutionResults, testFiles []*testing.PdxTest
 		builder.WriteString(" | ")
 		builder.WriteString(result.Date)
 		builder.WriteString(" | ")
+		builder.WriteString(formatTolerance(result.Test.AcceptableFailRate))
+		builder.WriteString(" | ")
+		if result.Test.Success != "" {
+			builder.WriteString("`")
+			builder.WriteString(strings.ReplaceAll(compactSource(result.Test.Success), "|", "\\|"))
+			builder.WriteString("`")
+		} else {
+			builder.WriteString(" - ")
+		}
+		builder.WriteString(" | ")
 		if result.Test.Description != "" {
 			builder.WriteString(result.Test.Description)
 		} else {
@@ -118,6 +178,8 @@ func WriteReport(results *testing.ExecutionResults, testFiles []*testing.PdxTest
 		} else {
 			builder.WriteString(result.TestFile.Name)
 		}
+		builder.WriteString(" | ")
+		builder.WriteString(result.TestFile.Origin())
 		builder.WriteString(" |\n")
 	}
 
@@ -129,3 +191,131 @@ func WriteReport(results *testing.ExecutionResults, testFiles []*testing.PdxTest
 
 	return nil
 }
+
+func writeOverrides(builder *strings.Builder, testFiles []*testing.PdxTestFile) {
+	overriding := make([]*testing.PdxTestFile, 0)
+	for _, file := range testFiles {
+		if file.Overrides != nil {
+			overriding = append(overriding, file)
+		}
+	}
+	if len(overriding) == 0 {
+		return
+	}
+	builder.WriteString("## Overridden Test Files\n\n")
+	builder.WriteString("| File | Used From | Overrides |\n")
+	builder.WriteString("|---|---|---|\n")
+	for _, file := range overriding {
+		builder.WriteString("| ")
+		builder.WriteString(filepath.ToSlash(file.RelativePath))
+		builder.WriteString(" | ")
+		builder.WriteString(file.Origin())
+		builder.WriteString(" | ")
+		chain := make([]string, 0)
+		for _, overridden := range file.OverrideChain() {
+			chain = append(chain, overridden.Origin())
+		}
+		builder.WriteString(strings.Join(chain, " → "))
+		builder.WriteString(" |\n")
+	}
+	builder.WriteString("\n")
+}
+
+func writeDuplicates(builder *strings.Builder, testFiles []*testing.PdxTestFile) {
+	duplicates := testing.FindDuplicateTests(testFiles)
+	if len(duplicates) == 0 {
+		return
+	}
+	builder.WriteString("## Duplicate Tests\n\n")
+	builder.WriteString("Results of these tests can not be attributed reliably to a test file.\n\n")
+	builder.WriteString("| Test | Files |\n")
+	builder.WriteString("|---|---|\n")
+	for _, duplicate := range duplicates {
+		builder.WriteString("| ")
+		builder.WriteString(duplicate.Name)
+		builder.WriteString(" | ")
+		files := make([]string, 0, len(duplicate.Files))
+		for _, file := range duplicate.Files {
+			files = append(files, fmt.Sprintf("%s (%s)", filepath.ToSlash(file.RelativePath), file.Origin()))
+		}
+		builder.WriteString(strings.Join(files, ", "))
+		builder.WriteString(" |\n")
+	}
+	builder.WriteString("\n")
+}
+
+func writeDiff(builder *strings.Builder, results *testing.ExecutionResults) {
+	if results.Diff == nil {
+		return
+	}
+	builder.WriteString("## Comparison with Baseline\n\n")
+	builder.WriteString("**Baseline:** ")
+	builder.WriteString(filepath.ToSlash(results.Baseline))
+	builder.WriteString("\n\n")
+	changed := 0
+	for _, testDiff := range results.Diff {
+		if testDiff.Status != testing.DiffUnchanged {
+			changed++
+		}
+	}
+	if changed == 0 {
+		builder.WriteString("No test changed compared to the baseline.\n\n")
+		return
+	}
+	builder.WriteString("| Change | Test | Baseline | Current |\n")
+	builder.WriteString("|---|---|---|---|\n")
+	for _, testDiff := range results.Diff {
+		if testDiff.Status == testing.DiffUnchanged {
+			continue
+		}
+		builder.WriteString("| ")
+		switch testDiff.Status {
+		case testing.DiffNewlyFailing:
+			builder.WriteString("❌ ")
+		case testing.DiffNewlyPassing:
+			builder.WriteString("✅ ")
+		}
+		builder.WriteString(string(testDiff.Status))
+		builder.WriteString(" | ")
+		builder.WriteString(testDiff.Name)
+		builder.WriteString(" | ")
+		builder.WriteString(diffStatus(testDiff.Baseline))
+		builder.WriteString(" | ")
+		builder.WriteString(diffStatus(testDiff.Current))
+		builder.WriteString(" |\n")
+	}
+	builder.WriteString("\n")
+}
+
+func diffStatus(status testing.HistoryStatus) string {
+	if status == "" {
+		return " - "
+	}
+	return string(status)
+}
+
+// formatTolerance formats an acceptable fail rate as percentage
+func formatTolerance(rate float64) string {
+	return strconv.FormatFloat(rate*100, 'f', -1, 64) + "%"
+}
+
+// compactSource collapses script source into a single shortened line
+func compactSource(source string) string {
+	const maxLength = 120
+	compact := []rune(strings.Join(strings.Fields(source), " "))
+	if len(compact) > maxLength {
+		return string(compact[:maxLength-1]) + "…"
+	}
+	return string(compact)
+}
+
+func gameName(gameType game.Type) string {
+	switch gameType {
+	case game.Victoria3:
+		return "Victoria 3"
+	case game.CrusaderKings3:
+		return "Crusader Kings 3"
+	default:
+		return "Unknown"
+	}
+}
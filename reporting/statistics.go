@@ -0,0 +1,112 @@
+package reporting
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"bahmut.de/pdx-test-runner/game"
+	"bahmut.de/pdx-test-runner/testing"
+)
+
+// WriteStatisticsReport writes a report aggregating the results of repeated test runs
+// into a new directory inside the output directory. It returns the path of the report.
+func WriteStatisticsReport(outputDirectory string, startTime time.Time, runs []*testing.ExecutionResults, statistics []*testing.TestStatistics, settings *game.LauncherSettings) (string, error) {
+	builder := strings.Builder{}
+
+	builder.WriteString("# Repeated Test Run - ")
+	builder.WriteString(startTime.Format(time.DateTime))
+	builder.WriteString("\n")
+	builder.WriteString("\n")
+	builder.WriteString("**Game:** ")
+	builder.WriteString(gameName(settings.GameType))
+	builder.WriteString("\n")
+	builder.WriteString("\n")
+	builder.WriteString("**Runs:** ")
+	builder.WriteString(strconv.Itoa(len(runs)))
+	builder.WriteString("\n")
+	builder.WriteString("\n")
+	builder.WriteString("## Runs\n\n")
+	builder.WriteString("| Run | Outcome | Successful | Failed | Duration | Output |\n")
+	builder.WriteString("|---|---|---|---|---|---|\n")
+	for i, run := range runs {
+		successes, failures := countResults(run)
+		builder.WriteString("| ")
+		builder.WriteString(strconv.Itoa(i + 1))
+		builder.WriteString(" | ")
+		builder.WriteString(run.Outcome.String())
+		builder.WriteString(" | ")
+		builder.WriteString(strconv.Itoa(successes))
+		builder.WriteString(" | ")
+		builder.WriteString(strconv.Itoa(failures))
+		builder.WriteString(" | ")
+		builder.WriteString(run.Duration.String())
+		builder.WriteString(" | ")
+		builder.WriteString(filepath.ToSlash(run.OutputDirectory))
+		builder.WriteString(" |\n")
+	}
+	builder.WriteString("\n")
+	builder.WriteString("## Test Statistics\n\n")
+	builder.WriteString("The confidence interval is the 95% Wilson score interval of the pass rate. ")
+	builder.WriteString("Tests failing more often than their tolerance are marked with ⚠️.\n\n")
+	builder.WriteString("| Test | Classification | Passed | Failed | Missing | Pass Rate | Confidence Interval | Tolerance | File |\n")
+	builder.WriteString("|---|---|---|---|---|---|---|---|---|\n")
+	for _, testStatistics := range statistics {
+		builder.WriteString("| ")
+		if testStatistics.Test.DisplayName != "" {
+			builder.WriteString(testStatistics.Test.DisplayName)
+			builder.WriteString(" (")
+			builder.WriteString(testStatistics.Test.Name)
+			builder.WriteString(")")
+		} else {
+			builder.WriteString(testStatistics.Test.Name)
+		}
+		if testStatistics.ExceedsTolerance() {
+			builder.WriteString(" ⚠️")
+		}
+		builder.WriteString(" | ")
+		builder.WriteString(string(testStatistics.Classification()))
+		builder.WriteString(" | ")
+		builder.WriteString(strconv.Itoa(testStatistics.Successes))
+		builder.WriteString(" | ")
+		builder.WriteString(strconv.Itoa(testStatistics.Failures))
+		builder.WriteString(" | ")
+		builder.WriteString(strconv.Itoa(testStatistics.Missing))
+		builder.WriteString(" | ")
+		if testStatistics.Runs() > 0 {
+			lower, upper := testStatistics.ConfidenceInterval()
+			builder.WriteString(formatRate(testStatistics.PassRate()))
+			builder.WriteString(" | ")
+			builder.WriteString(formatRate(lower))
+			builder.WriteString(" – ")
+			builder.WriteString(formatRate(upper))
+		} else {
+			builder.WriteString(" - | - ")
+		}
+		builder.WriteString(" | ")
+		builder.WriteString(formatTolerance(testStatistics.Test.AcceptableFailRate))
+		builder.WriteString(" | ")
+		builder.WriteString(testStatistics.TestFile.Name)
+		builder.WriteString(" |\n")
+	}
+
+	reportDirectory := filepath.Join(outputDirectory, startTime.Format("2006-01-02_15_04_05"))
+	err := os.MkdirAll(reportDirectory, os.ModePerm)
+	if err != nil {
+		return "", fmt.Errorf("error creating statistics report directory: %v", err)
+	}
+	reportFile := filepath.Join(reportDirectory, "statistics.md")
+	err = os.WriteFile(reportFile, []byte(builder.String()), os.ModePerm)
+	if err != nil {
+		return "", fmt.Errorf("error writing statistics report: %v", err)
+	}
+	return reportFile, nil
+}
+
+// formatRate formats a rate as percentage with one decimal place
+func formatRate(rate float64) string {
+	return strconv.FormatFloat(rate*100, 'f', 1, 64) + "%"
+}
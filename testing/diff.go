@@ -0,0 +1,91 @@
+package testing
+
+import "sort"
+
+// DiffStatus classifies the change of a test compared to a baseline run
+type DiffStatus string
+
+const (
+	DiffNewlyFailing DiffStatus = "newly failing"
+	DiffNewlyPassing DiffStatus = "newly passing"
+	DiffStillFailing DiffStatus = "still failing"
+	DiffAdded        DiffStatus = "added"
+	DiffRemoved      DiffStatus = "removed"
+	DiffNewlyIgnored DiffStatus = "newly ignored"
+	DiffNoResult     DiffStatus = "no result" // Active, but without result unlike in the baseline
+	DiffUnchanged    DiffStatus = "unchanged"
+)
+
+// Order in which changes are reported, most important first
+var diffStatusOrder = []DiffStatus{
+	DiffNewlyFailing,
+	DiffNoResult,
+	DiffStillFailing,
+	DiffNewlyPassing,
+	DiffAdded,
+	DiffRemoved,
+	DiffNewlyIgnored,
+	DiffUnchanged,
+}
+
+// TestDiff is the change of a single test compared to a baseline run
+type TestDiff struct {
+	Name     string
+	Status   DiffStatus
+	Baseline HistoryStatus // Empty if the test did not exist in the baseline
+	Current  HistoryStatus // Empty if the test does not exist anymore
+}
+
+// DiffRuns classifies every test of both runs by its change from the baseline to the current run.
+// The changes are sorted by importance and name.
+func DiffRuns(baseline, current *HistoryRun) []*TestDiff {
+	diffs := make(map[string]*TestDiff)
+	for _, test := range baseline.Tests {
+		diffs[test.Name] = &TestDiff{Name: test.Name, Baseline: test.Status}
+	}
+	for _, test := range current.Tests {
+		if diff, ok := diffs[test.Name]; ok {
+			diff.Current = test.Status
+		} else {
+			diffs[test.Name] = &TestDiff{Name: test.Name, Current: test.Status}
+		}
+	}
+
+	result := make([]*TestDiff, 0, len(diffs))
+	for _, diff := range diffs {
+		diff.Status = classifyDiff(diff.Baseline, diff.Current)
+		result = append(result, diff)
+	}
+	order := make(map[DiffStatus]int)
+	for i, status := range diffStatusOrder {
+		order[status] = i
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Status != result[j].Status {
+			return order[result[i].Status] < order[result[j].Status]
+		}
+		return result[i].Name < result[j].Name
+	})
+	return result
+}
+
+func classifyDiff(baseline, current HistoryStatus) DiffStatus {
+	switch {
+	case baseline == "":
+		return DiffAdded
+	case current == "":
+		return DiffRemoved
+	case current == HistoryIgnored && baseline != HistoryIgnored:
+		return DiffNewlyIgnored
+	case current == HistoryFailed && baseline == HistoryFailed:
+		return DiffStillFailing
+	case current == HistoryFailed:
+		return DiffNewlyFailing
+	case current == HistoryPassed && baseline == HistoryFailed:
+		return DiffNewlyPassing
+	case current == HistoryMissing && baseline != HistoryMissing:
+		return DiffNoResult
+	default:
+		return DiffUnchanged
+	}
+}
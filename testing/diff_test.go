@@ -0,0 +1,53 @@
+package testing
+
+import (
+	"strings"
+	gotesting "testing"
+)
+
+func TestClassifyDiff(t *gotesting.T) {
+	tests := []struct {
+		baseline HistoryStatus
+		current  HistoryStatus
+		expected DiffStatus
+	}{
+		{"", HistoryPassed, DiffAdded},
+		{"", HistoryFailed, DiffAdded},
+		{HistoryPassed, "", DiffRemoved},
+		{HistoryPassed, HistoryFailed, DiffNewlyFailing},
+		{HistoryMissing, HistoryFailed, DiffNewlyFailing},
+		{HistoryIgnored, HistoryFailed, DiffNewlyFailing},
+		{HistoryFailed, HistoryFailed, DiffStillFailing},
+		{HistoryFailed, HistoryPassed, DiffNewlyPassing},
+		{HistoryMissing, HistoryPassed, DiffUnchanged},
+		{HistoryPassed, HistoryPassed, DiffUnchanged},
+		{HistoryPassed, HistoryMissing, DiffNoResult},
+		{HistoryFailed, HistoryMissing, DiffNoResult},
+		{HistoryMissing, HistoryMissing, DiffUnchanged},
+		{HistoryFailed, HistoryIgnored, DiffNewlyIgnored},
+		{HistoryIgnored, HistoryIgnored, DiffUnchanged},
+	}
+	for _, test := range tests {
+		t.Run(string(test.baseline)+" to "+string(test.current), func(t *gotesting.T) {
+			if actual := classifyDiff(test.baseline, test.current); actual != test.expected {
+				t.Errorf("expected %q, got %q", test.expected, actual)
+			}
+		})
+	}
+}
+
+func TestDiffRuns(t *gotesting.T) {
+	runs := newHistoryRuns(
+		"test_fixed:failed test_broken:passed test_gone:passed test_lost:passed test_same:passed",
+		"test_same:passed test_new:passed test_lost:missing test_broken:failed test_fixed:passed",
+	)
+	diffs := DiffRuns(runs[0], runs[1])
+	described := make([]string, 0, len(diffs))
+	for _, diff := range diffs {
+		described = append(described, diff.Name+":"+string(diff.Status))
+	}
+	expected := "test_broken:newly failing test_lost:no result test_fixed:newly passing test_new:added test_gone:removed test_same:unchanged"
+	if actual := strings.Join(described, " "); actual != expected {
+		t.Errorf("expected %q, got %q", expected, actual)
+	}
+}
@@ -0,0 +1,177 @@
+package testing
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+const (
+	filterPrefixFile = "file:"
+	filterPrefixTest = "test:"
+	filterPrefixBase = "base:"
+	filterPrefixMod  = "mod:"
+)
+
+// testFilter matches tests by name, by the name of their test file or by their origin.
+// Supported patterns:
+//   - "/regex/" matches test names and file names with a regular expression
+//   - "file:<glob>" matches file names with a glob pattern
+//   - "test:<glob>" matches test names with a glob pattern
+//   - "base:<glob>" matches test names and file names of base game tests with a glob pattern
+//   - "mod:<glob>" matches all tests of mods by mod name or mod directory name with a glob pattern
+//   - "<glob>" matches test names and file names with a glob pattern
+type testFilter struct {
+	pattern string
+	regex   *regexp.Regexp
+	glob    string
+	files   bool
+	tests   bool
+	base    bool // Only match base game tests
+	mods    bool // Match the mod instead of test and file names
+}
+
+func newTestFilter(pattern string) (*testFilter, error) {
+	filter := &testFilter{pattern: pattern, files: true, tests: true}
+	switch {
+	case len(pattern) > 1 && strings.HasPrefix(pattern, "/") && strings.HasSuffix(pattern, "/"):
+		regex, err := regexp.Compile(pattern[1 : len(pattern)-1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid test filter (%s): %v", pattern, err)
+		}
+		filter.regex = regex
+		return filter, nil
+	case strings.HasPrefix(pattern, filterPrefixFile):
+		filter.glob = strings.TrimPrefix(pattern, filterPrefixFile)
+		filter.tests = false
+	case strings.HasPrefix(pattern, filterPrefixTest):
+		filter.glob = strings.TrimPrefix(pattern, filterPrefixTest)
+		filter.files = false
+	case strings.HasPrefix(pattern, filterPrefixBase):
+		filter.glob = strings.TrimPrefix(pattern, filterPrefixBase)
+		filter.base = true
+	case strings.HasPrefix(pattern, filterPrefixMod):
+		filter.glob = strings.TrimPrefix(pattern, filterPrefixMod)
+		filter.mods = true
+	default:
+		filter.glob = pattern
+	}
+	if _, err := path.Match(filter.glob, ""); err != nil {
+		return nil, fmt.Errorf("invalid test filter (%s): %v", pattern, err)
+	}
+	return filter, nil
+}
+
+func (filter *testFilter) matches(file *PdxTestFile, test *PdxTest) bool {
+	if filter.base && file.Mod != "" {
+		return false
+	}
+	if filter.mods {
+		if file.Mod == "" {
+			return false
+		}
+		matchedDirectory, _ := path.Match(filter.glob, file.Mod)
+		matchedName, _ := path.Match(filter.glob, file.ModName)
+		return matchedDirectory || matchedName
+	}
+	candidates := make([]string, 0, 2)
+	if filter.tests {
+		candidates = append(candidates, test.Name)
+	}
+	if filter.files {
+		candidates = append(candidates, strings.TrimSuffix(file.Name, ignoreSuffix))
+	}
+	for _, candidate := range candidates {
+		if filter.regex != nil {
+			if filter.regex.MatchString(candidate) {
+				return true
+			}
+			continue
+		}
+		if matched, _ := path.Match(filter.glob, candidate); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// SelectTests marks every test as ignored that does not match any of the run patterns
+// (if there are any) or matches one of the skip patterns.
+func SelectTests(testFiles []*PdxTestFile, runPatterns []string, skipPatterns []string) error {
+	runFilters, err := newTestFilters(runPatterns)
+	if err != nil {
+		return err
+	}
+	skipFilters, err := newTestFilters(skipPatterns)
+	if err != nil {
+		return err
+	}
+	for _, file := range testFiles {
+		for _, test := range file.Tests {
+			test.Ignored = false
+			if len(runFilters) > 0 && !anyFilterMatches(runFilters, file, test) {
+				test.Ignored = true
+			}
+			if anyFilterMatches(skipFilters, file, test) {
+				test.Ignored = true
+			}
+		}
+	}
+	return nil
+}
+
+func newTestFilters(patterns []string) ([]*testFilter, error) {
+	filters := make([]*testFilter, 0, len(patterns))
+	for _, pattern := range patterns {
+		filter, err := newTestFilter(pattern)
+		if err != nil {
+			return nil, err
+		}
+		filters = append(filters, filter)
+	}
+	return filters, nil
+}
+
+func anyFilterMatches(filters []*testFilter, file *PdxTestFile, test *PdxTest) bool {
+	for _, filter := range filters {
+		if filter.matches(file, test) {
+			return true
+		}
+	}
+	return false
+}
+
+// selectedTestCount returns the number of tests in the file not ignored by the test filters
+func selectedTestCount(file *PdxTestFile) int {
+	count := 0
+	for _, test := range file.Tests {
+		if !test.Ignored {
+			count++
+		}
+	}
+	return count
+}
+
+// filteredTestFileContent returns the content of the test file without the blocks of ignored tests
+func filteredTestFileContent(file *PdxTestFile) ([]byte, error) {
+	content, err := os.ReadFile(file.Path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read test file (%s): %v", file.Path, err)
+	}
+	removed := make([]*PdxTest, 0)
+	for _, test := range file.Tests {
+		if test.Ignored {
+			removed = append(removed, test)
+		}
+	}
+	sort.Slice(removed, func(i, j int) bool {
+		return removed[i].start > removed[j].start
+	})
+	for _, test := range removed {
+		content = append(content[:test.start:test.start], content[test.end:]...)
+	}
+	return content, nil
+}
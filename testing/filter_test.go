@@ -0,0 +1,112 @@
+package testing
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	gotesting "testing"
+)
+
+func TestSelectTests(t *gotesting.T) {
+	newFiles := func() []*PdxTestFile {
+		return []*PdxTestFile{
+			{Name: "economy.txt", Tests: []*PdxTest{{Name: "test_money"}, {Name: "test_debt"}}},
+			{Name: "war.txt.ignore", Tests: []*PdxTest{{Name: "test_war"}}},
+			{Name: "gate.txt", Mod: "gate_mod", ModName: "Gate", Tests: []*PdxTest{{Name: "test_gate_money"}}},
+		}
+	}
+
+	tests := []struct {
+		name     string
+		run      []string
+		skip     []string
+		selected string
+	}{
+		{"no patterns", nil, nil, "test_money test_debt test_war test_gate_money"},
+		{"glob matches test names", []string{"*money"}, nil, "test_money test_gate_money"},
+		{"glob matches file names", []string{"economy.txt"}, nil, "test_money test_debt"},
+		{"glob matches file names without ignore suffix", []string{"war.txt"}, nil, "test_war"},
+		{"test prefix", []string{"test:*war*"}, nil, "test_war"},
+		{"test prefix ignores file names", []string{"test:economy.txt"}, nil, ""},
+		{"file prefix", []string{"file:gate.txt"}, nil, "test_gate_money"},
+		{"file prefix ignores test names", []string{"file:test_money"}, nil, ""},
+		{"base prefix", []string{"base:*money"}, nil, "test_money"},
+		{"mod prefix matches mod name", []string{"mod:Gate"}, nil, "test_gate_money"},
+		{"mod prefix matches mod directory", []string{"mod:gate_*"}, nil, "test_gate_money"},
+		{"regex", []string{"/^test_(war|debt)$/"}, nil, "test_debt test_war"},
+		{"skip", nil, []string{"test_debt", "mod:*"}, "test_money test_war"},
+		{"run and skip", []string{"file:economy.txt", "test_war"}, []string{"test_money"}, "test_debt test_war"},
+		{"skip wins over run", []string{"test_money"}, []string{"test_money"}, ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *gotesting.T) {
+			files := newFiles()
+			err := SelectTests(files, test.run, test.skip)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			selected := make([]string, 0)
+			for _, file := range files {
+				for _, pdxTest := range file.Tests {
+					if !pdxTest.Ignored {
+						selected = append(selected, pdxTest.Name)
+					}
+				}
+			}
+			if actual := strings.Join(selected, " "); actual != test.selected {
+				t.Errorf("expected %q, got %q", test.selected, actual)
+			}
+		})
+	}
+}
+
+func TestNewTestFiltersErrors(t *gotesting.T) {
+	for _, pattern := range []string{"/(/", "file:[", "test:[", "base:[", "mod:[", "["} {
+		t.Run(pattern, func(t *gotesting.T) {
+			_, err := newTestFilters([]string{"valid", pattern})
+			if err == nil {
+				t.Errorf("expected an error for %q", pattern)
+			}
+		})
+	}
+}
+
+func TestFilteredTestFileContent(t *gotesting.T) {
+	content := "last_date = 1837.1.1\n\n# Money\ntest_money = { success = { always = yes } }\n\n### desc = Debt\ntest_debt = { success = { always = yes } }\n\nwrapper = {\n\ttest_war = { fail = { always = no } }\n}\n"
+	path := filepath.Join(t.TempDir(), "economy.txt")
+	err := os.WriteFile(path, []byte(content), os.ModePerm)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		run      []string
+		skip     []string
+		expected string
+	}{
+		{"all selected", nil, nil, content},
+		{"partially selected", []string{"test_money"}, nil, "last_date = 1837.1.1\n\n# Money\ntest_money = { success = { always = yes } }\n\n\n\nwrapper = {\n\t\n}\n"},
+		{"run and skip", []string{"economy.txt"}, []string{"test_debt"}, "last_date = 1837.1.1\n\n# Money\ntest_money = { success = { always = yes } }\n\n\n\nwrapper = {\n\ttest_war = { fail = { always = no } }\n}\n"},
+		{"nested test removed", nil, []string{"test_war"}, "last_date = 1837.1.1\n\n# Money\ntest_money = { success = { always = yes } }\n\n### desc = Debt\ntest_debt = { success = { always = yes } }\n\nwrapper = {\n\t\n}\n"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *gotesting.T) {
+			file, err := parseTestFile(path)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			err = SelectTests([]*PdxTestFile{file}, test.run, test.skip)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			filtered, err := filteredTestFileContent(file)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if string(filtered) != test.expected {
+				t.Errorf("expected %q, got %q", test.expected, string(filtered))
+			}
+		})
+	}
+}
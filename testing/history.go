@@ -0,0 +1,195 @@
+package testing
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+const historyFileName = "history.jsonl"
+
+type HistoryStatus string
+
+const (
+	HistoryPassed  HistoryStatus = "passed"
+	HistoryFailed  HistoryStatus = "failed"
+	HistoryMissing HistoryStatus = "missing" // Active, but without result
+	HistoryIgnored HistoryStatus = "ignored"
+)
+
+// HistoryRun is a test run recorded in the run history
+type HistoryRun struct {
+	StartTime       time.Time      `json:"start-time"`
+	EndTime         time.Time      `json:"end-time"`
+	Outcome         string         `json:"outcome"`
+	OutputDirectory string         `json:"output-directory"`
+	Tests           []*HistoryTest `json:"tests"`
+}
+
+type HistoryTest struct {
+	Name   string        `json:"name"`
+	File   string        `json:"file"`
+	Origin string        `json:"origin"`
+	Status HistoryStatus `json:"status"`
+}
+
+// TestHistory is the history of a single test over multiple runs
+type TestHistory struct {
+	Name         string
+	Statuses     []HistoryStatus // Status in each of the last runs, oldest first (empty if the test did not exist)
+	FailingSince *HistoryRun     // First run of the current streak of failures (nil if the test is not failing)
+	Passed       int             // Passed runs over the whole history
+	Failed       int             // Failed runs over the whole history
+}
+
+// PassRate returns the share of runs (0 to 1) the test passed in over the whole history
+func (history *TestHistory) PassRate() float64 {
+	if history.Passed+history.Failed == 0 {
+		return 0
+	}
+	return float64(history.Passed) / float64(history.Passed+history.Failed)
+}
+
+// NewHistoryRun returns the status of every test in the test run
+func NewHistoryRun(results *ExecutionResults, testFiles []*PdxTestFile) *HistoryRun {
+	run := &HistoryRun{
+		StartTime:       results.StartTime,
+		EndTime:         results.EndTime,
+		Outcome:         results.Outcome.String(),
+		OutputDirectory: results.OutputDirectory,
+		Tests:           make([]*HistoryTest, 0),
+	}
+	found := make(map[*PdxTest]*TestResult)
+	for _, result := range results.TestResults {
+		found[result.Test] = result
+	}
+	for _, file := range testFiles {
+		for _, test := range file.Tests {
+			status := HistoryIgnored
+			if result, ok := found[test]; ok && result.Success {
+				status = HistoryPassed
+			} else if ok {
+				status = HistoryFailed
+			} else if file.IsTestActive(test) {
+				status = HistoryMissing
+			}
+			run.Tests = append(run.Tests, &HistoryTest{
+				Name:   test.Name,
+				File:   filepath.ToSlash(file.RelativePath),
+				Origin: file.Origin(),
+				Status: status,
+			})
+		}
+	}
+	return run
+}
+
+// AppendHistory records the test run in the run history of the output directory.
+// The history is a JSON lines file with one test run per line.
+func AppendHistory(outputDirectory string, run *HistoryRun) error {
+	content, err := json.Marshal(run)
+	if err != nil {
+		return fmt.Errorf("could not encode run history: %v", err)
+	}
+	path := filepath.Join(outputDirectory, historyFileName)
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, os.ModePerm)
+	if err != nil {
+		return fmt.Errorf("could not open run history (%s): %v", path, err)
+	}
+	_, err = file.Write(append(content, '\n'))
+	if err != nil {
+		_ = file.Close()
+		return fmt.Errorf("could not write run history (%s): %v", path, err)
+	}
+	err = file.Close()
+	if err != nil {
+		return fmt.Errorf("could not write run history (%s): %v", path, err)
+	}
+	return nil
+}
+
+// ReadHistory reads all recorded test runs of the output directory, oldest first.
+// A missing history results in no runs.
+func ReadHistory(outputDirectory string) ([]*HistoryRun, error) {
+	path := filepath.Join(outputDirectory, historyFileName)
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return make([]*HistoryRun, 0), nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not open run history (%s): %v", path, err)
+	}
+	defer func(file *os.File) {
+		_ = file.Close()
+	}(file)
+
+	runs := make([]*HistoryRun, 0)
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line++
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		run := &HistoryRun{}
+		err = json.Unmarshal(scanner.Bytes(), run)
+		if err != nil {
+			return nil, fmt.Errorf("could not parse run history (%s:%v): %v", path, line, err)
+		}
+		runs = append(runs, run)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("could not read run history (%s): %v", path, err)
+	}
+	sort.SliceStable(runs, func(i, j int) bool {
+		return runs[i].StartTime.Before(runs[j].StartTime)
+	})
+	return runs, nil
+}
+
+// SummarizeHistory returns the history of every test in the recorded runs, sorted by name.
+// Statuses only cover the given number of most recent runs.
+func SummarizeHistory(runs []*HistoryRun, last int) []*TestHistory {
+	histories := make(map[string]*TestHistory)
+	recentStart := max(len(runs)-last, 0)
+	for index, run := range runs {
+		for _, test := range run.Tests {
+			history, ok := histories[test.Name]
+			if !ok {
+				history = &TestHistory{
+					Name:     test.Name,
+					Statuses: make([]HistoryStatus, len(runs)-recentStart),
+				}
+				histories[test.Name] = history
+			}
+			if index >= recentStart {
+				history.Statuses[index-recentStart] = test.Status
+			}
+			switch test.Status {
+			case HistoryPassed:
+				history.Passed++
+				history.FailingSince = nil
+			case HistoryFailed:
+				history.Failed++
+				if history.FailingSince == nil {
+					history.FailingSince = run
+				}
+			}
+		}
+	}
+
+	result := make([]*TestHistory, 0, len(histories))
+	for _, history := range histories {
+		result = append(result, history)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
+	return result
+}
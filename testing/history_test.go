@@ -0,0 +1,90 @@
+package testing
+
+import (
+	"strings"
+	gotesting "testing"
+)
+
+// newHistoryRuns returns one run per status string, e.g. "test_a:passed test_b:failed"
+func newHistoryRuns(runs ...string) []*HistoryRun {
+	result := make([]*HistoryRun, 0, len(runs))
+	for _, run := range runs {
+		historyRun := &HistoryRun{Tests: make([]*HistoryTest, 0)}
+		for _, test := range strings.Fields(run) {
+			name, status, _ := strings.Cut(test, ":")
+			historyRun.Tests = append(historyRun.Tests, &HistoryTest{Name: name, Status: HistoryStatus(status)})
+		}
+		result = append(result, historyRun)
+	}
+	return result
+}
+
+func TestSummarizeHistory(t *gotesting.T) {
+	runs := newHistoryRuns(
+		"test_a:passed test_b:passed test_c:failed",
+		"test_a:failed test_b:passed test_c:passed",
+		"test_a:failed test_b:ignored test_c:missing test_d:passed",
+		"test_a:failed test_b:failed",
+	)
+
+	tests := []struct {
+		name         string
+		last         int
+		test         string
+		statuses     string
+		passed       int
+		failed       int
+		failingSince int // Index of the run, -1 if not failing
+	}{
+		{"failing streak", 10, "test_a", "passed failed failed failed", 1, 3, 1},
+		{"newly failing after ignored run", 10, "test_b", "passed passed ignored failed", 2, 1, 3},
+		{"fixed", 10, "test_c", "failed passed missing -", 1, 1, -1},
+		{"added later", 10, "test_d", "- - passed -", 1, 0, -1},
+		{"statuses of last runs only", 2, "test_a", "failed failed", 1, 3, 1},
+		{"more runs requested than recorded", 5, "test_d", "- - passed -", 1, 0, -1},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *gotesting.T) {
+			var history *TestHistory
+			for _, summary := range SummarizeHistory(runs, test.last) {
+				if summary.Name == test.test {
+					history = summary
+				}
+			}
+			if history == nil {
+				t.Fatalf("no history of %s", test.test)
+			}
+			statuses := make([]string, 0, len(history.Statuses))
+			for _, status := range history.Statuses {
+				if status == "" {
+					status = "-"
+				}
+				statuses = append(statuses, string(status))
+			}
+			if actual := strings.Join(statuses, " "); actual != test.statuses {
+				t.Errorf("expected statuses %q, got %q", test.statuses, actual)
+			}
+			if history.Passed != test.passed || history.Failed != test.failed {
+				t.Errorf("expected %v passed and %v failed, got %v and %v", test.passed, test.failed, history.Passed, history.Failed)
+			}
+			var failingSince *HistoryRun
+			if test.failingSince >= 0 {
+				failingSince = runs[test.failingSince]
+			}
+			if history.FailingSince != failingSince {
+				t.Errorf("expected failing since run %v", test.failingSince)
+			}
+		})
+	}
+}
+
+func TestSummarizeHistorySortsByName(t *gotesting.T) {
+	histories := SummarizeHistory(newHistoryRuns("test_c:passed test_a:passed", "test_b:failed"), 10)
+	names := make([]string, 0, len(histories))
+	for _, history := range histories {
+		names = append(names, history.Name)
+	}
+	if actual := strings.Join(names, " "); actual != "test_a test_b test_c" {
+		t.Errorf("unexpected order: %q", actual)
+	}
+}
@@ -3,26 +3,139 @@ package testing
 import (
 	"fmt"
 	"os"
+	"path"
+	"path/filepath"
 	"strings"
 )
 
 const ignoreSuffix = ".ignore"
 
-func DeactivateTestFiles(testFiles []*PdxTestFile, ignoreFiles []string) error {
+const (
+	ignorePrefixBase   = "base:"
+	ignorePrefixMod    = "mod:"
+	ignorePrefixNegate = "!"
+)
+
+// ignorePattern matches test files to ignore. Supported patterns:
+//   - "<glob>" matches the file name or the relative path of files from the base game and all mods
+//   - "base:<glob>" matches the relative path of base game files
+//   - "mod:<mod glob>" matches all files of a mod by its directory name or its name
+//   - "mod:<mod glob>/<glob>" matches the relative path of files of a mod
+//   - "!<pattern>" negates the pattern, so matching files are not ignored
+//
+// When multiple patterns match a file, the last one decides.
+type ignorePattern struct {
+	pattern string
+	negated bool
+	base    bool
+	mods    bool
+	mod     string
+	glob    string
+}
+
+func newIgnorePattern(pattern string) (*ignorePattern, error) {
+	ignore := &ignorePattern{pattern: pattern, base: true, mods: true, mod: "*"}
+	rest, negated := strings.CutPrefix(pattern, ignorePrefixNegate)
+	ignore.negated = negated
+	switch {
+	case strings.HasPrefix(rest, ignorePrefixBase):
+		ignore.mods = false
+		ignore.glob = strings.TrimPrefix(rest, ignorePrefixBase)
+	case strings.HasPrefix(rest, ignorePrefixMod):
+		ignore.base = false
+		mod, glob, found := strings.Cut(strings.TrimPrefix(rest, ignorePrefixMod), "/")
+		ignore.mod = mod
+		ignore.glob = glob
+		if !found {
+			ignore.glob = "**"
+		}
+	default:
+		ignore.glob = rest
+	}
+	for _, glob := range []string{ignore.mod, ignore.glob} {
+		if _, err := path.Match(glob, ""); err != nil {
+			return nil, fmt.Errorf("invalid ignore pattern (%s): %v", pattern, err)
+		}
+	}
+	return ignore, nil
+}
+
+func (ignore *ignorePattern) matches(file *PdxTestFile) bool {
+	if file.Mod == "" && !ignore.base {
+		return false
+	}
+	if file.Mod != "" {
+		if !ignore.mods {
+			return false
+		}
+		matchedDirectory, _ := path.Match(ignore.mod, file.Mod)
+		matchedName, _ := path.Match(ignore.mod, file.ModName)
+		if !matchedDirectory && !matchedName {
+			return false
+		}
+	}
+	if ignore.glob == "**" {
+		return true
+	}
+	relativePath := filepath.ToSlash(file.RelativePath)
+	if matched, _ := path.Match(ignore.glob, relativePath); matched {
+		return true
+	}
+	// Plain patterns keep matching just the file name
+	if !strings.Contains(ignore.glob, "/") {
+		matched, _ := path.Match(ignore.glob, path.Base(relativePath))
+		return matched
+	}
+	return false
+}
+
+// matchIgnorePatterns decides for every test file whether it is ignored
+// and remembers the deciding pattern in IgnoredBy.
+// It returns the ignored test files.
+func matchIgnorePatterns(testFiles []*PdxTestFile, ignoreFiles []string) (map[*PdxTestFile]bool, error) {
+	patterns := make([]*ignorePattern, 0, len(ignoreFiles))
+	for _, ignoreFile := range ignoreFiles {
+		pattern, err := newIgnorePattern(ignoreFile)
+		if err != nil {
+			return nil, err
+		}
+		patterns = append(patterns, pattern)
+	}
+	ignored := make(map[*PdxTestFile]bool)
 	for _, testFile := range testFiles {
-		deactivated := false
-		for _, ignoreFile := range ignoreFiles {
-			if ignoreFile == testFile.Name {
-				err := deactivateTestFile(testFile)
-				if err != nil {
-					return err
-				}
-				deactivated = true
-				break
+		testFile.IgnoredBy = ""
+		for _, pattern := range patterns {
+			if pattern.matches(testFile) {
+				testFile.IgnoredBy = pattern.pattern
+				ignored[testFile] = !pattern.negated
 			}
 		}
-		if !deactivated {
-			err := activateTestFile(testFile)
+	}
+	return ignored, nil
+}
+
+// DeactivateTestFiles renames ignored test files, so the game does not run them.
+// Test files with only some tests selected are replaced by a generated test file containing only those tests.
+// Every change is recorded in the journal before it is performed.
+func DeactivateTestFiles(testFiles []*PdxTestFile, ignoreFiles []string, journal *Journal) error {
+	ignored, err := matchIgnorePatterns(testFiles, ignoreFiles)
+	if err != nil {
+		return err
+	}
+	for _, testFile := range testFiles {
+		if ignored[testFile] || selectedTestCount(testFile) == 0 {
+			err := deactivateTestFile(testFile, journal)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		err := activateTestFile(testFile)
+		if err != nil {
+			return err
+		}
+		if selectedTestCount(testFile) < len(testFile.Tests) {
+			err = filterTestFile(testFile, journal)
 			if err != nil {
 				return err
 			}
@@ -31,20 +144,30 @@ func DeactivateTestFiles(testFiles []*PdxTestFile, ignoreFiles []string) error {
 	return nil
 }
 
-func ActivateTestFiles(testFiles []*PdxTestFile) error {
+// ActivateTestFiles restores all deactivated test files and clears the journal
+func ActivateTestFiles(testFiles []*PdxTestFile, journal *Journal) error {
 	for _, testFile := range testFiles {
 		err := activateTestFile(testFile)
 		if err != nil {
 			return err
 		}
 	}
-	return nil
+	// Restore anything not covered by the parsed test files
+	return journal.Restore()
 }
 
-func deactivateTestFile(file *PdxTestFile) error {
+func deactivateTestFile(file *PdxTestFile, journal *Journal) error {
 	if !strings.HasSuffix(file.Path, ignoreSuffix) {
 		deactivatedName := file.Path + ignoreSuffix
-		err := os.Rename(file.Path, deactivatedName)
+		err := journal.Record(&JournalEntry{
+			Action: JournalRename,
+			From:   file.Path,
+			To:     deactivatedName,
+		})
+		if err != nil {
+			return err
+		}
+		err = os.Rename(file.Path, deactivatedName)
 		if err != nil {
 			return fmt.Errorf("could not deactivate test file (%s): %v", file.Path, err)
 		}
@@ -54,6 +177,37 @@ func deactivateTestFile(file *PdxTestFile) error {
 	return nil
 }
 
+// filterTestFile replaces the test file with a generated test file only containing the selected tests.
+// The original test file is deactivated and restored by the journal.
+func filterTestFile(file *PdxTestFile, journal *Journal) error {
+	content, err := filteredTestFileContent(file)
+	if err != nil {
+		return err
+	}
+	deactivatedName := file.Path + ignoreSuffix
+	err = journal.Record(&JournalEntry{
+		Action: JournalRename,
+		From:   file.Path,
+		To:     deactivatedName,
+	})
+	if err != nil {
+		return err
+	}
+	err = os.Rename(file.Path, deactivatedName)
+	if err != nil {
+		return fmt.Errorf("could not deactivate test file (%s): %v", file.Path, err)
+	}
+	err = journal.RecordCreate(file.Path)
+	if err != nil {
+		return err
+	}
+	err = os.WriteFile(file.Path, content, os.ModePerm)
+	if err != nil {
+		return fmt.Errorf("could not write filtered test file (%s): %v", file.Path, err)
+	}
+	return nil
+}
+
 func activateTestFile(file *PdxTestFile) error {
 	if strings.HasSuffix(file.Path, ignoreSuffix) {
 		activatedName, _ := strings.CutSuffix(file.Path, ignoreSuffix)
@@ -0,0 +1,71 @@
+package testing
+
+import (
+	"path/filepath"
+	gotesting "testing"
+)
+
+func TestMatchIgnorePatterns(t *gotesting.T) {
+	base := &PdxTestFile{RelativePath: "economy.txt"}
+	baseNested := &PdxTestFile{RelativePath: filepath.Join("war", "battle.txt")}
+	mod := &PdxTestFile{RelativePath: "economy.txt", Mod: "gate_mod", ModName: "Gate"}
+	modNested := &PdxTestFile{RelativePath: filepath.Join("war", "siege.txt"), Mod: "gate_mod", ModName: "Gate"}
+	otherMod := &PdxTestFile{RelativePath: "economy.txt", Mod: "other_mod"}
+	testFiles := []*PdxTestFile{base, baseNested, mod, modNested, otherMod}
+
+	tests := []struct {
+		name      string
+		patterns  []string
+		ignored   []*PdxTestFile
+		ignoredBy map[*PdxTestFile]string
+	}{
+		{"no patterns", nil, nil, nil},
+		{"file name", []string{"economy.txt"}, []*PdxTestFile{base, mod, otherMod}, nil},
+		{"base name fallback for globs without slash", []string{"s*.txt"}, []*PdxTestFile{modNested}, nil},
+		{"relative path", []string{"war/*.txt"}, []*PdxTestFile{baseNested, modNested}, nil},
+		{"glob with slash matches only relative path", []string{"*/economy.txt"}, nil, nil},
+		{"base prefix", []string{"base:*"}, []*PdxTestFile{base, baseNested}, nil},
+		{"mod prefix with mod name", []string{"mod:Gate"}, []*PdxTestFile{mod, modNested}, nil},
+		{"mod prefix with mod directory glob", []string{"mod:*_mod"}, []*PdxTestFile{mod, modNested, otherMod}, nil},
+		{"mod prefix with path", []string{"mod:gate_mod/war/*"}, []*PdxTestFile{modNested}, nil},
+		{"negation", []string{"*.txt", "!mod:Gate"}, []*PdxTestFile{base, baseNested, otherMod}, nil},
+		{"negation without earlier match", []string{"!economy.txt"}, nil, nil},
+		{
+			"last match wins",
+			[]string{"economy.txt", "!base:*", "base:economy.txt"},
+			[]*PdxTestFile{base, mod, otherMod},
+			map[*PdxTestFile]string{base: "base:economy.txt", baseNested: "!base:*", mod: "economy.txt"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *gotesting.T) {
+			ignored, err := matchIgnorePatterns(testFiles, test.patterns)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			expected := make(map[*PdxTestFile]bool)
+			for _, file := range test.ignored {
+				expected[file] = true
+			}
+			for _, file := range testFiles {
+				if ignored[file] != expected[file] {
+					t.Errorf("expected ignored %v for %s (%s), got %v", expected[file], file.RelativePath, file.Origin(), ignored[file])
+				}
+				if ignoredBy, ok := test.ignoredBy[file]; ok && file.IgnoredBy != ignoredBy {
+					t.Errorf("expected %s (%s) to be decided by %q, got %q", file.RelativePath, file.Origin(), ignoredBy, file.IgnoredBy)
+				}
+			}
+		})
+	}
+}
+
+func TestMatchIgnorePatternsErrors(t *gotesting.T) {
+	for _, pattern := range []string{"[", "base:[", "mod:[", "mod:gate/[", "![", "!mod:["} {
+		t.Run(pattern, func(t *gotesting.T) {
+			_, err := matchIgnorePatterns(nil, []string{"economy.txt", pattern})
+			if err == nil {
+				t.Errorf("expected an error for %q", pattern)
+			}
+		})
+	}
+}
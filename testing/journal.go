@@ -0,0 +1,212 @@
+package testing
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"bahmut.de/pdx-test-runner/logging"
+)
+
+const journalFileName = "restore-journal.json"
+
+type JournalAction string
+
+const (
+	JournalRename JournalAction = "rename" // From was renamed to To
+	JournalCreate JournalAction = "create" // From was created and is removed on restore
+	JournalBackup JournalAction = "backup" // From was backed up to To before it was changed
+)
+
+type JournalEntry struct {
+	Action JournalAction `json:"action"`
+	From   string        `json:"from"`
+	To     string        `json:"to,omitempty"`
+}
+
+// Journal records every change made to game or mod files,
+// so they can be restored even if the test runner crashes.
+// The journal is written to disk before each change is performed.
+type Journal struct {
+	path    string
+	Owner   int             `json:"owner,omitempty"` // Process id of the test runner that recorded the changes
+	Entries []*JournalEntry `json:"entries"`
+}
+
+// OpenJournal loads the journal from the given directory.
+// If no journal exists an empty journal is returned.
+func OpenJournal(directory string) (*Journal, error) {
+	journal := &Journal{
+		path:    filepath.Join(directory, journalFileName),
+		Entries: make([]*JournalEntry, 0),
+	}
+	content, err := os.ReadFile(journal.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return journal, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not read restore journal (%s): %v", journal.path, err)
+	}
+	err = json.Unmarshal(content, journal)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse restore journal (%s): %v", journal.path, err)
+	}
+	return journal, nil
+}
+
+func (journal *Journal) Path() string {
+	return journal.path
+}
+
+// Stale reports whether the journal contains changes that were never restored.
+// Changes of a test run that is still running are not stale.
+func (journal *Journal) Stale() bool {
+	return len(journal.Entries) > 0 && !journal.OwnerRunning()
+}
+
+// OwnerRunning reports whether the journal belongs to another test runner that is still running
+func (journal *Journal) OwnerRunning() bool {
+	if len(journal.Entries) == 0 || journal.Owner == 0 || journal.Owner == os.Getpid() {
+		return false
+	}
+	return processRunning(journal.Owner)
+}
+
+// Record adds an entry and persists the journal before the change is performed
+func (journal *Journal) Record(entry *JournalEntry) error {
+	journal.Owner = os.Getpid()
+	journal.Entries = append(journal.Entries, entry)
+	return journal.save()
+}
+
+// RecordCreate records a file or directory that is about to be created
+func (journal *Journal) RecordCreate(path string) error {
+	return journal.Record(&JournalEntry{
+		Action: JournalCreate,
+		From:   path,
+	})
+}
+
+// RecordBackup backs up a file next to the journal before it is changed.
+// If the file does not exist yet, it is recorded as created instead.
+func (journal *Journal) RecordBackup(path string) error {
+	content, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return journal.RecordCreate(path)
+	}
+	if err != nil {
+		return fmt.Errorf("could not read file for backup (%s): %v", path, err)
+	}
+	backupPath := filepath.Join(filepath.Dir(journal.path), fmt.Sprintf("backup-%v-%s", len(journal.Entries), filepath.Base(path)))
+	err = os.MkdirAll(filepath.Dir(backupPath), os.ModePerm)
+	if err != nil {
+		return fmt.Errorf("could not create backup directory: %v", err)
+	}
+	err = os.WriteFile(backupPath, content, os.ModePerm)
+	if err != nil {
+		return fmt.Errorf("could not write backup (%s): %v", backupPath, err)
+	}
+	return journal.Record(&JournalEntry{
+		Action: JournalBackup,
+		From:   path,
+		To:     backupPath,
+	})
+}
+
+// Restore undoes all recorded changes in reverse order and removes the journal
+func (journal *Journal) Restore() error {
+	for len(journal.Entries) > 0 {
+		entry := journal.Entries[len(journal.Entries)-1]
+		err := restoreEntry(entry)
+		if err != nil {
+			return err
+		}
+		journal.Entries = journal.Entries[:len(journal.Entries)-1]
+		err = journal.save()
+		if err != nil {
+			return err
+		}
+	}
+	return journal.Clear()
+}
+
+// Clear removes the journal after all changes were restored
+func (journal *Journal) Clear() error {
+	journal.Entries = make([]*JournalEntry, 0)
+	err := os.Remove(journal.path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("could not remove restore journal (%s): %v", journal.path, err)
+	}
+	return nil
+}
+
+func (journal *Journal) save() error {
+	if len(journal.Entries) == 0 {
+		return journal.Clear()
+	}
+	content, err := json.MarshalIndent(journal, "", "  ")
+	if err != nil {
+		return fmt.Errorf("could not encode restore journal: %v", err)
+	}
+	err = os.MkdirAll(filepath.Dir(journal.path), os.ModePerm)
+	if err != nil {
+		return fmt.Errorf("could not create restore journal directory: %v", err)
+	}
+	// Write to a temporary file first, so a crash never leaves a broken journal behind
+	temporaryPath := journal.path + ".tmp"
+	err = os.WriteFile(temporaryPath, content, os.ModePerm)
+	if err != nil {
+		return fmt.Errorf("could not write restore journal (%s): %v", journal.path, err)
+	}
+	err = os.Rename(temporaryPath, journal.path)
+	if err != nil {
+		return fmt.Errorf("could not write restore journal (%s): %v", journal.path, err)
+	}
+	return nil
+}
+
+func restoreEntry(entry *JournalEntry) error {
+	switch entry.Action {
+	case JournalRename:
+		if _, err := os.Stat(entry.To); errors.Is(err, os.ErrNotExist) {
+			// The rename was recorded but never performed or was already restored
+			return nil
+		}
+		logging.Debugf("Restoring %s", entry.From)
+		err := os.Rename(entry.To, entry.From)
+		if err != nil {
+			return fmt.Errorf("could not restore renamed file (%s): %v", entry.From, err)
+		}
+		return nil
+	case JournalCreate:
+		logging.Debugf("Removing %s", entry.From)
+		err := os.RemoveAll(entry.From)
+		if err != nil {
+			return fmt.Errorf("could not remove created file (%s): %v", entry.From, err)
+		}
+		return nil
+	case JournalBackup:
+		content, err := os.ReadFile(entry.To)
+		if errors.Is(err, os.ErrNotExist) {
+			// The backup was already restored
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("could not read backup (%s): %v", entry.To, err)
+		}
+		logging.Debugf("Restoring %s", entry.From)
+		err = os.WriteFile(entry.From, content, os.ModePerm)
+		if err != nil {
+			return fmt.Errorf("could not restore backup (%s): %v", entry.From, err)
+		}
+		err = os.Remove(entry.To)
+		if err != nil {
+			return fmt.Errorf("could not remove backup (%s): %v", entry.To, err)
+		}
+		return nil
+	default:
+		return fmt.Errorf("unsupported restore journal action: %s", entry.Action)
+	}
+}
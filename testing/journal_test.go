@@ -0,0 +1,101 @@
+package testing
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	gotesting "testing"
+)
+
+func TestJournalRestore(t *gotesting.T) {
+	directory := t.TempDir()
+	journal, err := OpenJournal(directory)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	renamed := filepath.Join(directory, "economy.txt")
+	err = os.WriteFile(renamed, []byte("test_money = { success = { always = yes } }"), os.ModePerm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = journal.Record(&JournalEntry{Action: JournalRename, From: renamed, To: renamed + ignoreSuffix})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.Rename(renamed, renamed+ignoreSuffix)
+	if err != nil {
+		t.Fatal(err)
+	}
+	created := filepath.Join(directory, "instances", "instance-1")
+	err = journal.RecordCreate(created)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.MkdirAll(filepath.Join(created, "save games"), os.ModePerm)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Restore from disk like after a crash
+	journal, err = OpenJournal(directory)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(journal.Entries) != 2 || !journal.Stale() {
+		t.Fatalf("expected a stale journal with 2 entries, got %v entries", len(journal.Entries))
+	}
+	err = journal.Restore()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := os.Stat(renamed); err != nil {
+		t.Errorf("renamed file was not restored: %v", err)
+	}
+	for _, path := range []string{renamed + ignoreSuffix, created, journal.Path()} {
+		if _, err := os.Stat(path); !errors.Is(err, os.ErrNotExist) {
+			t.Errorf("expected %s to be removed", path)
+		}
+	}
+}
+
+func TestJournalOwner(t *gotesting.T) {
+	// A process that has already exited
+	exited := exec.Command(os.Args[0], "-test.run=^$")
+	err := exited.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		owner   int
+		running bool
+	}{
+		{"running owner", os.Getppid(), true},
+		{"exited owner", exited.Process.Pid, false},
+		{"own process", os.Getpid(), false},
+		{"unknown owner", 0, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *gotesting.T) {
+			directory := t.TempDir()
+			journal, err := OpenJournal(directory)
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = journal.RecordCreate(filepath.Join(directory, "instances"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			journal.Owner = test.owner
+			if journal.OwnerRunning() != test.running {
+				t.Errorf("expected owner running %v, got %v", test.running, journal.OwnerRunning())
+			}
+			if journal.Stale() == test.running {
+				t.Errorf("expected stale %v, got %v", !test.running, journal.Stale())
+			}
+		})
+	}
+}
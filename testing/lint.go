@@ -0,0 +1,178 @@
+package testing
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+
+	"bahmut.de/pdx-test-runner/game"
+)
+
+var regexDate = regexp.MustCompile(`^(\d{1,4})\.(\d{1,2})\.(\d{1,2})$`)
+
+// LintIssue is a problem found in a scripted test file without running the game
+type LintIssue struct {
+	Path     string
+	Position ScriptPosition
+	Message  string
+}
+
+func (issue *LintIssue) String() string {
+	return fmt.Sprintf("%s:%d: %s", issue.Path, issue.Position.Line, issue.Message)
+}
+
+// LintTestFiles statically checks all scripted test files of the base game and the mods.
+// The issues are sorted by file and line.
+func LintTestFiles(gamePath string, modPaths []string, gameType game.Type) ([]*LintIssue, error) {
+	issues := make([]*LintIssue, 0)
+	lint := func(file string) (*PdxTestFile, error) {
+		content, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		script, err := ParseScript(file, content)
+		var scriptError *ScriptError
+		if errors.As(err, &scriptError) {
+			issues = append(issues, &LintIssue{Path: file, Position: scriptError.Position, Message: scriptError.Message})
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		issues = append(issues, lintScript(script)...)
+		return newPdxTestFile(file, script), nil
+	}
+
+	ignoreList := baseIgnoreList[gameType]
+	testFiles, err := walkTestDirectory(filepath.Join(gamePath, "tools", "scripted_tests"), ignoreList, lint)
+	if err != nil {
+		return nil, err
+	}
+	for _, modPath := range modPaths {
+		modTests, err := walkTestDirectory(filepath.Join(modPath, "tools", "scripted_tests"), ignoreList, lint)
+		if err != nil {
+			return nil, err
+		}
+		testFiles = mergeTestFiles(testFiles, modTests)
+	}
+
+	for _, duplicate := range FindDuplicateTests(testFiles) {
+		first := duplicate.Tests[0]
+		for _, test := range duplicate.Tests[1:] {
+			issues = append(issues, &LintIssue{
+				Path:     test.Path,
+				Position: test.Position,
+				Message:  fmt.Sprintf("duplicate test name '%s' (first defined in %s:%d)", duplicate.Name, first.Path, first.Position.Line),
+			})
+		}
+	}
+
+	sort.SliceStable(issues, func(i, j int) bool {
+		if issues[i].Path != issues[j].Path {
+			return issues[i].Path < issues[j].Path
+		}
+		return issues[i].Position.Offset < issues[j].Position.Offset
+	})
+	return issues, nil
+}
+
+// DuplicateTest is a test name defined by multiple tests
+type DuplicateTest struct {
+	Name  string
+	Tests []*PdxTest
+	Files []*PdxTestFile // File of each test
+}
+
+// FindDuplicateTests returns all test names defined multiple times, sorted by name
+func FindDuplicateTests(testFiles []*PdxTestFile) []*DuplicateTest {
+	testsByName := make(map[string]*DuplicateTest)
+	for _, file := range testFiles {
+		for _, test := range file.Tests {
+			duplicate, ok := testsByName[test.Name]
+			if !ok {
+				duplicate = &DuplicateTest{Name: test.Name}
+				testsByName[test.Name] = duplicate
+			}
+			duplicate.Tests = append(duplicate.Tests, test)
+			duplicate.Files = append(duplicate.Files, file)
+		}
+	}
+	duplicates := make([]*DuplicateTest, 0)
+	for _, duplicate := range testsByName {
+		if len(duplicate.Tests) > 1 {
+			duplicates = append(duplicates, duplicate)
+		}
+	}
+	sort.Slice(duplicates, func(i, j int) bool {
+		return duplicates[i].Name < duplicates[j].Name
+	})
+	return duplicates
+}
+
+func lintScript(script *ScriptFile) []*LintIssue {
+	issues := make([]*LintIssue, 0)
+	issue := func(position ScriptPosition, format string, v ...any) {
+		issues = append(issues, &LintIssue{Path: script.Path, Position: position, Message: fmt.Sprintf(format, v...)})
+	}
+
+	attached := make(map[*ScriptComment]bool)
+	if header := fileNameAnnotation(script); header != nil {
+		attached[header] = true
+	}
+	for _, test := range findTestNodes(script.Nodes) {
+		for _, comment := range test.Comments {
+			attached[comment] = true
+		}
+		if test.Child("success") == nil {
+			issue(test.Start, "test '%s' has no success block", test.Key)
+		} else if !test.Child("success").IsBlock {
+			issue(test.Child("success").Start, "success of test '%s' is not a block", test.Key)
+		}
+		if test.Child("fail") == nil {
+			issue(test.Start, "test '%s' has no fail block", test.Key)
+		} else if !test.Child("fail").IsBlock {
+			issue(test.Child("fail").Start, "fail of test '%s' is not a block", test.Key)
+		}
+		if rate := test.Child("acceptable_fail_rate"); rate != nil {
+			value, err := strconv.ParseFloat(rate.Value, 64)
+			if rate.IsBlock || err != nil || value < 0 || value > 1 {
+				issue(rate.Start, "invalid acceptable_fail_rate '%s' of test '%s' (must be a number between 0 and 1)", rate.Value, test.Key)
+			}
+		}
+	}
+
+	for _, comment := range script.Comments {
+		if annotation, _ := parseAnnotation(comment); annotation != "" && !attached[comment] {
+			issue(comment.Start, "### %s annotation is not attached to any test", annotation)
+		}
+	}
+
+	lintLastDates(script.Nodes, issue)
+	return issues
+}
+
+func lintLastDates(nodes []*ScriptNode, issue func(ScriptPosition, string, ...any)) {
+	for _, node := range nodes {
+		if node.Key == "last_date" && !isValidDate(node) {
+			issue(node.Start, "malformed last_date '%s' (expected year.month.day)", node.Value)
+		}
+		lintLastDates(node.Children, issue)
+	}
+}
+
+func isValidDate(node *ScriptNode) bool {
+	if node.IsBlock {
+		return false
+	}
+	match := regexDate.FindStringSubmatch(node.Value)
+	if match == nil {
+		return false
+	}
+	month, _ := strconv.Atoi(match[2])
+	day, _ := strconv.Atoi(match[3])
+	return month >= 1 && month <= 12 && day >= 1 && day <= 31
+}
@@ -0,0 +1,97 @@
+package testing
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"bahmut.de/pdx-test-runner/game"
+	"bahmut.de/pdx-test-runner/logging"
+)
+
+const overlayModName = "pdx-test-runner-overlay"
+
+// DeactivateTestFilesWithOverlay ignores test files without changing any game or mod files.
+// Instead, it generates an overlay mod, loaded after all other mods,
+// that overrides every ignored test file with an empty file
+// and every partially selected test file with a file only containing the selected tests.
+// The overlay mod and the changed content load file are recorded in the journal.
+func DeactivateTestFilesWithOverlay(settings *game.LauncherSettings, testFiles []*PdxTestFile, ignoreFiles []string, journal *Journal) error {
+	ignored, err := matchIgnorePatterns(testFiles, ignoreFiles)
+	if err != nil {
+		return err
+	}
+
+	modDirectory := game.LocalModDirectory(settings, overlayModName)
+	if _, err := os.Stat(modDirectory); err == nil {
+		return fmt.Errorf("overlay mod directory already exists: %s", modDirectory)
+	}
+
+	err = journal.RecordCreate(modDirectory)
+	if err != nil {
+		return err
+	}
+	if descriptorFile := game.ModDescriptorFile(settings, modDirectory); descriptorFile != "" {
+		err = journal.RecordCreate(descriptorFile)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, testFile := range testFiles {
+		switch {
+		case ignored[testFile] || selectedTestCount(testFile) == 0:
+			err = writeOverlayFile(modDirectory, testFile.RelativePath, nil)
+			if err != nil {
+				return err
+			}
+			testFile.Ignored = true
+		case selectedTestCount(testFile) < len(testFile.Tests):
+			content, err := filteredTestFileContent(testFile)
+			if err != nil {
+				return err
+			}
+			err = writeOverlayFile(modDirectory, testFile.RelativePath, content)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	reference, err := game.WriteModDescriptor(settings, modDirectory, "PDX Test Runner Overlay")
+	if err != nil {
+		return err
+	}
+
+	contentLoad, err := game.ReadContentLoad(settings)
+	if err != nil {
+		return err
+	}
+	err = journal.RecordBackup(contentLoad.Path)
+	if err != nil {
+		return err
+	}
+	contentLoad.AppendMod(reference)
+	err = contentLoad.Write()
+	if err != nil {
+		return err
+	}
+	logging.Debugf("Enabled overlay mod: %s", modDirectory)
+
+	return nil
+}
+
+// writeOverlayFile writes a scripted test file into the overlay mod,
+// which replaces the test file with the same relative path of the base game and all other mods.
+func writeOverlayFile(modDirectory, relativePath string, content []byte) error {
+	file := filepath.Join(modDirectory, "tools", "scripted_tests", relativePath)
+	err := os.MkdirAll(filepath.Dir(file), os.ModePerm)
+	if err != nil {
+		return fmt.Errorf("could not create overlay mod directory: %v", err)
+	}
+	err = os.WriteFile(file, content, os.ModePerm)
+	if err != nil {
+		return fmt.Errorf("could not write overlay test file (%s): %v", file, err)
+	}
+	return nil
+}
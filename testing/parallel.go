@@ -0,0 +1,335 @@
+package testing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"bahmut.de/pdx-test-runner/config"
+	"bahmut.de/pdx-test-runner/game"
+	"bahmut.de/pdx-test-runner/logging"
+)
+
+const shardModName = "pdx-test-runner-shard"
+
+// Placeholders in instance arguments and environment variables
+const (
+	placeholderInstanceDirectory = "{instance-directory}"
+	placeholderDataPath          = "{data-path}"
+)
+
+// gameInstance is a game process running a shard of the test files with its own data directory
+type gameInstance struct {
+	number    int
+	directory string
+	settings  *game.LauncherSettings // Settings with the data path of the instance
+	testFiles []*PdxTestFile         // Test files run by this instance
+}
+
+// RunTestsInParallel shards the active test files across multiple game instances running at the same time.
+//
+// Every instance gets its own data directory, so test results and save games do not collide.
+// The game has to be told about the data directory with instance arguments or environment variables.
+// Each instance loads the mods of the original data path and a shard mod,
+// which overrides the test files of all other instances with empty files.
+// The results of all instances are merged into a single test run.
+func RunTestsInParallel(ctx context.Context, settings *game.LauncherSettings, config *config.TestRunnerConfig, testFiles []*PdxTestFile, journal *Journal) (*ExecutionResults, error) {
+	shards := shardTestFiles(testFiles, config.Instances)
+	instanceRoot, err := filepath.Abs(instanceRootDirectory(config))
+	if err != nil {
+		return nil, fmt.Errorf("invalid instance directory: %v", err)
+	}
+	_, err = os.Stat(instanceRoot)
+	createdRoot := errors.Is(err, os.ErrNotExist)
+	if createdRoot {
+		err = journal.RecordCreate(instanceRoot)
+		if err != nil {
+			return nil, err
+		}
+	}
+	instances := make([]*gameInstance, 0, len(shards))
+	// Instance directories are removed after the results were collected
+	defer func() {
+		for _, instance := range instances {
+			err := os.RemoveAll(instance.directory)
+			if err != nil {
+				logging.Errorf("Could not remove instance directory (%s): %v", instance.directory, err)
+			}
+		}
+		if createdRoot {
+			// Only removed if empty, so nothing unexpected is deleted
+			err := os.Remove(instanceRoot)
+			if err != nil && !errors.Is(err, os.ErrNotExist) {
+				logging.Errorf("Could not remove instance directory (%s): %v", instanceRoot, err)
+			}
+		}
+	}()
+	for i, shard := range shards {
+		instance, err := prepareInstance(settings, config, testFiles, shard, instanceRoot, i+1, journal)
+		if err != nil {
+			return nil, err
+		}
+		instances = append(instances, instance)
+	}
+
+	// The first failing instance stops all others, their results would be discarded anyway
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	progress := newResultProgress(len(activeTestNames(testFiles)))
+	startTime := time.Now()
+	outcomes := make([]Outcome, len(instances))
+	errs := make([]error, len(instances))
+	var workers sync.WaitGroup
+	for i, instance := range instances {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			logging.Infof("Starting instance %v with %v test files", instance.number, len(instance.testFiles))
+			label := fmt.Sprintf("Instance %v: ", instance.number)
+			stream := newResultStream(instance.resultFile(), label, activeTestNames(instance.testFiles), progress)
+			outcomes[i], errs[i] = runGame(ctx, instance.command(config), stream, config)
+			if errs[i] != nil {
+				logging.Errorf("Instance %v failed: %v", instance.number, errs[i])
+				cancel()
+				return
+			}
+			logging.Infof("Instance %v finished: %s", instance.number, outcomes[i])
+		}()
+	}
+	workers.Wait()
+	endTime := time.Now()
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+
+	results, err := collectInstanceResults(instances, config, testFiles)
+	if err != nil {
+		return nil, err
+	}
+	results.Outcome = mergeOutcomes(outcomes)
+	results.StartTime = startTime
+	results.EndTime = endTime
+	results.Duration = endTime.Sub(startTime)
+
+	return results, nil
+}
+
+// shardTestFiles distributes all test files with active tests across the given number of shards,
+// so every shard runs about the same number of tests
+func shardTestFiles(testFiles []*PdxTestFile, count int) [][]*PdxTestFile {
+	active := make([]*PdxTestFile, 0)
+	for _, file := range testFiles {
+		if activeTestCount(file) > 0 {
+			active = append(active, file)
+		}
+	}
+	sort.SliceStable(active, func(i, j int) bool {
+		return activeTestCount(active[i]) > activeTestCount(active[j])
+	})
+
+	count = max(min(count, len(active)), 1)
+	shards := make([][]*PdxTestFile, count)
+	sizes := make([]int, count)
+	for _, file := range active {
+		// Largest files first into the smallest shard
+		smallest := 0
+		for i := range shards {
+			if sizes[i] < sizes[smallest] {
+				smallest = i
+			}
+		}
+		shards[smallest] = append(shards[smallest], file)
+		sizes[smallest] += activeTestCount(file)
+	}
+	return shards
+}
+
+func activeTestCount(file *PdxTestFile) int {
+	count := 0
+	for _, test := range file.Tests {
+		if file.IsTestActive(test) {
+			count++
+		}
+	}
+	return count
+}
+
+// prepareInstance creates the data directory of an instance.
+// It enables the same mods as the original data path and a shard mod disabling the test files of other shards.
+func prepareInstance(settings *game.LauncherSettings, config *config.TestRunnerConfig, testFiles, shard []*PdxTestFile, instanceRoot string, number int, journal *Journal) (*gameInstance, error) {
+	directory := filepath.Join(instanceRoot, fmt.Sprintf("instance-%v", number))
+	instanceSettings := *settings
+	instanceSettings.DataPath = filepath.Join(directory, config.InstanceDataPath)
+	instance := &gameInstance{
+		number:    number,
+		directory: directory,
+		settings:  &instanceSettings,
+		testFiles: shard,
+	}
+	modDirectory := game.LocalModDirectory(instance.settings, shardModName)
+
+	if _, err := os.Stat(directory); err == nil {
+		if _, err := os.Stat(modDirectory); err != nil {
+			return nil, fmt.Errorf("instance directory already exists: %s", directory)
+		}
+		// Left behind by a test run that could not clean up (e.g. because the game still locked files)
+		logging.Warnf("Removing instance directory of an earlier test run: %s", directory)
+		err = os.RemoveAll(directory)
+		if err != nil {
+			return nil, fmt.Errorf("could not remove instance directory of an earlier test run: %v", err)
+		}
+	}
+	err := journal.RecordCreate(directory)
+	if err != nil {
+		return nil, err
+	}
+	err = os.MkdirAll(filepath.Join(instanceSettings.DataPath, saveGameDirectoryName), os.ModePerm)
+	if err != nil {
+		return nil, fmt.Errorf("could not create instance directory: %v", err)
+	}
+
+	// Disable the test files of all other shards
+	inShard := make(map[*PdxTestFile]bool)
+	for _, file := range shard {
+		inShard[file] = true
+	}
+	err = os.MkdirAll(modDirectory, os.ModePerm)
+	if err != nil {
+		return nil, fmt.Errorf("could not create shard mod directory: %v", err)
+	}
+	for _, file := range testFiles {
+		if !inShard[file] && activeTestCount(file) > 0 {
+			err = writeOverlayFile(modDirectory, file.RelativePath, nil)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	shardReference, err := game.WriteModDescriptor(instance.settings, modDirectory, fmt.Sprintf("PDX Test Runner Shard %v", number))
+	if err != nil {
+		return nil, err
+	}
+
+	// Enable the original mods and the shard mod
+	contentLoad, err := game.ReadContentLoad(settings)
+	if err != nil {
+		return nil, err
+	}
+	references := make([]string, 0)
+	for _, reference := range contentLoad.EnabledMods() {
+		relocated, err := game.RelocateModReference(settings, instance.settings, reference)
+		if err != nil {
+			return nil, err
+		}
+		references = append(references, relocated)
+	}
+	contentLoad.Path = game.ContentLoadPath(instance.settings)
+	contentLoad.SetEnabledMods(append(references, shardReference))
+	err = contentLoad.Write()
+	if err != nil {
+		return nil, err
+	}
+	logging.Debugf("Prepared instance %v: %s", number, directory)
+
+	return instance, nil
+}
+
+// instanceRootDirectory returns the directory containing the data directories of all instances
+func instanceRootDirectory(config *config.TestRunnerConfig) string {
+	if config.InstanceDirectory == "" {
+		return filepath.Join(config.OutputDirectory, "instances")
+	}
+	return config.InstanceDirectory
+}
+
+func (instance *gameInstance) resultFile() string {
+	return filepath.Join(instance.settings.DataPath, ResultFileName)
+}
+
+// command returns the game command with the instance arguments and environment variables
+func (instance *gameInstance) command(config *config.TestRunnerConfig) *exec.Cmd {
+	replacer := strings.NewReplacer(
+		placeholderInstanceDirectory, instance.directory,
+		placeholderDataPath, instance.settings.DataPath,
+	)
+	arguments := make([]string, 0, len(config.InstanceArguments))
+	for _, argument := range config.InstanceArguments {
+		arguments = append(arguments, replacer.Replace(argument))
+	}
+	binary := gameCommand(instance.settings.ExecPath, arguments...)
+	binary.Env = os.Environ()
+	for name, value := range config.InstanceEnvironment {
+		binary.Env = append(binary.Env, name+"="+replacer.Replace(value))
+	}
+	return binary
+}
+
+// collectInstanceResults merges the result files and save games of all instances into one output directory
+func collectInstanceResults(instances []*gameInstance, config *config.TestRunnerConfig, testFiles []*PdxTestFile) (*ExecutionResults, error) {
+	runOutputDirectory, err := createRunOutputDirectory(config)
+	if err != nil {
+		return nil, err
+	}
+
+	content := make([]byte, 0)
+	saveGames := make([]string, 0)
+	for _, instance := range instances {
+		instanceContent, err := os.ReadFile(instance.resultFile())
+		if errors.Is(err, os.ErrNotExist) {
+			// The game may have crashed or hung before writing any results
+			logging.Errorf("Test result file of instance %v does not exist: %s", instance.number, instance.resultFile())
+		} else if err != nil {
+			return nil, fmt.Errorf("could not read test result file: %v", err)
+		}
+		if len(instanceContent) > 0 && !strings.HasSuffix(string(instanceContent), "\n") {
+			instanceContent = append(instanceContent, '\n')
+		}
+		content = append(content, instanceContent...)
+
+		// Save games are removed with the instance directory, so there is nothing to move
+		saveDirectory := filepath.Join(instance.settings.DataPath, saveGameDirectoryName)
+		instanceSaveGames, err := collectSaveGames(saveDirectory, runOutputDirectory, instance.settings.GameType, false)
+		if err != nil {
+			return nil, err
+		}
+		saveGames = append(saveGames, instanceSaveGames...)
+	}
+
+	resultFile := filepath.Join(runOutputDirectory, ResultFileName)
+	err = os.WriteFile(resultFile, content, os.ModePerm)
+	if err != nil {
+		return nil, fmt.Errorf("could not write merged test result file: %v", err)
+	}
+	testResults, err := parseTestResults(resultFile, testFiles)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ExecutionResults{
+		OutputDirectory: runOutputDirectory,
+		TestResults:     testResults,
+		SaveGames:       saveGames,
+	}, nil
+}
+
+// mergeOutcomes returns the outcome of all instances together.
+// An interruption takes precedence, otherwise the first incomplete instance decides.
+func mergeOutcomes(outcomes []Outcome) Outcome {
+	merged := OutcomeCompleted
+	for _, outcome := range outcomes {
+		if outcome == OutcomeInterrupted {
+			return OutcomeInterrupted
+		}
+		if merged == OutcomeCompleted {
+			merged = outcome
+		}
+	}
+	return merged
+}
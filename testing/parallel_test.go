@@ -0,0 +1,81 @@
+package testing
+
+import (
+	"strings"
+	gotesting "testing"
+)
+
+// newShardTestFile returns a test file with the given number of active and ignored tests
+func newShardTestFile(name string, active, ignored int) *PdxTestFile {
+	file := &PdxTestFile{Name: name}
+	for range active {
+		file.Tests = append(file.Tests, &PdxTest{})
+	}
+	for range ignored {
+		file.Tests = append(file.Tests, &PdxTest{Ignored: true})
+	}
+	return file
+}
+
+// describeShards returns a compact form of the shards for comparisons, e.g. "a.txt b.txt | c.txt"
+func describeShards(shards [][]*PdxTestFile) string {
+	parts := make([]string, 0, len(shards))
+	for _, shard := range shards {
+		names := make([]string, 0, len(shard))
+		for _, file := range shard {
+			names = append(names, file.Name)
+		}
+		parts = append(parts, strings.Join(names, " "))
+	}
+	return strings.Join(parts, " | ")
+}
+
+func TestShardTestFiles(t *gotesting.T) {
+	large := newShardTestFile("large.txt", 5, 0)
+	medium := newShardTestFile("medium.txt", 3, 1)
+	small := newShardTestFile("small.txt", 2, 0)
+	tiny := newShardTestFile("tiny.txt", 1, 0)
+	unselected := newShardTestFile("unselected.txt", 0, 4)
+	ignoredFile := newShardTestFile("ignored.txt.ignore", 4, 0)
+	ignoredFile.Ignored = true
+
+	tests := []struct {
+		name      string
+		testFiles []*PdxTestFile
+		count     int
+		expected  string
+	}{
+		{"single shard", []*PdxTestFile{tiny, large, small}, 1, "large.txt small.txt tiny.txt"},
+		{"balanced shards", []*PdxTestFile{tiny, small, medium, large}, 2, "large.txt tiny.txt | medium.txt small.txt"},
+		{"more instances than files", []*PdxTestFile{small, large}, 4, "large.txt | small.txt"},
+		{"files without active tests", []*PdxTestFile{unselected, small, ignoredFile, tiny}, 2, "small.txt | tiny.txt"},
+		{"no active tests", []*PdxTestFile{unselected, ignoredFile}, 3, ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *gotesting.T) {
+			if actual := describeShards(shardTestFiles(test.testFiles, test.count)); actual != test.expected {
+				t.Errorf("expected %q, got %q", test.expected, actual)
+			}
+		})
+	}
+}
+
+func TestMergeOutcomes(t *gotesting.T) {
+	tests := []struct {
+		name     string
+		outcomes []Outcome
+		expected Outcome
+	}{
+		{"all completed", []Outcome{OutcomeCompleted, OutcomeCompleted}, OutcomeCompleted},
+		{"no instances", []Outcome{}, OutcomeCompleted},
+		{"first incomplete instance decides", []Outcome{OutcomeCompleted, OutcomeStalled, OutcomeTimedOut}, OutcomeStalled},
+		{"interrupted takes precedence", []Outcome{OutcomeGameExited, OutcomeCompleted, OutcomeInterrupted}, OutcomeInterrupted},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *gotesting.T) {
+			if actual := mergeOutcomes(test.outcomes); actual != test.expected {
+				t.Errorf("expected %s, got %s", test.expected, actual)
+			}
+		})
+	}
+}
@@ -1,9 +1,12 @@
 package testing
 
 import (
+	"errors"
+	"fmt"
 	"os"
 	"path/filepath"
 	"regexp"
+	"strconv"
 	"strings"
 
 	"bahmut.de/pdx-test-runner/game"
@@ -12,9 +15,15 @@ import (
 
 const activeTestSuffix = ".txt"
 
-var regexTestDisplayName = regexp.MustCompile(`^### name\s*=\s*(?P<name>.*)`)
-var regexLastDate = regexp.MustCompile(`last_date\s*=\s*(.*)`)
-var regexTest = regexp.MustCompile(`(?m)(?:^\s*###\s*name\s*=\s*(?P<name>.+)\s*)*\s*(?:^\s*###\s*desc\s*=\s*(?P<desc>.+)\s*)*\s*(?P<test>[a-zA-Z_\-0-9]+)\s*=\s*{\s+(?:acceptable_fail_rate|success|fail)`)
+var regexAnnotation = regexp.MustCompile(`^###\s*(name|desc)\s*=\s*(.*?)\s*$`)
+
+const (
+	annotationName        = "name"
+	annotationDescription = "desc"
+)
+
+// Keys identifying a block as scripted test
+var testKeys = []string{"acceptable_fail_rate", "success", "fail"}
 
 // Base game files that should not be parsed
 var baseIgnoreList = map[game.Type][]string{
@@ -27,17 +36,60 @@ var baseIgnoreList = map[game.Type][]string{
 }
 
 type PdxTestFile struct {
-	Ignored     bool
-	Name        string
-	DisplayName string
-	Path        string
-	LastDate    string
-	Tests       []*PdxTest
+	Ignored      bool
+	Name         string
+	DisplayName  string
+	Path         string
+	Root         string       // Scripted tests directory containing the file
+	RelativePath string       // Path relative to the scripted tests directory (without ignore suffix)
+	Mod          string       // Directory name of the mod defining the file (empty for the base game)
+	ModName      string       // Name of the mod from its metadata (empty for the base game)
+	IgnoredBy    string       // Ignore pattern deciding whether the file is ignored (empty if none matched)
+	Overrides    *PdxTestFile // File with the same relative path replaced by this file (nil if none)
+	LastDate     string
+	Tests        []*PdxTest
 }
 type PdxTest struct {
+	Ignored     bool // Not selected by the test filters
 	Name        string
 	DisplayName string
 	Description string
+	Path        string         // Path of the test file at parse time
+	Position    ScriptPosition // Position of the test name in the test file
+
+	AcceptableFailRate float64           // Share of runs (0 to 1) allowed to fail
+	Success            string            // Source of the success trigger
+	Fail               string            // Source of the fail trigger
+	Fields             map[string]string // Source of all other fields by key
+
+	// Byte range of the test block (including annotations) in the test file
+	start int
+	end   int
+}
+
+// Origin describes where the test file comes from (base game or mod)
+func (file *PdxTestFile) Origin() string {
+	if file.Mod == "" {
+		return "base game"
+	}
+	if file.ModName != "" {
+		return "mod " + file.ModName
+	}
+	return "mod " + file.Mod
+}
+
+// OverrideChain returns all files replaced by this file, starting with the directly overridden one
+func (file *PdxTestFile) OverrideChain() []*PdxTestFile {
+	chain := make([]*PdxTestFile, 0)
+	for overridden := file.Overrides; overridden != nil; overridden = overridden.Overrides {
+		chain = append(chain, overridden)
+	}
+	return chain
+}
+
+// IsTestActive reports whether the test is run by the game
+func (file *PdxTestFile) IsTestActive(test *PdxTest) bool {
+	return !file.Ignored && !test.Ignored
 }
 
 func GetTestFiles(gamePath string, modPaths []string, gameType game.Type) ([]*PdxTestFile, error) {
@@ -57,24 +109,55 @@ func GetTestFiles(gamePath string, modPaths []string, gameType game.Type) ([]*Pd
 		if err != nil {
 			return nil, err
 		}
+		metadata, err := game.ReadModMetadata(modPath, gameType)
+		if err != nil {
+			return nil, err
+		}
+		for _, modTest := range modTests {
+			modTest.Mod = filepath.Base(modPath)
+			modTest.ModName = metadata.Name
+		}
 		testFiles = mergeTestFiles(testFiles, modTests)
 	}
 
 	// Remove empty tests
 	results := make([]*PdxTestFile, 0)
 	for _, testFile := range testFiles {
+		if testFile.Overrides != nil {
+			logging.Warnf("%s from %s overrides %s", testFile.RelativePath, testFile.Origin(), testFile.Overrides.Origin())
+		}
 		if len(testFile.Tests) == 0 {
 			continue
 		}
 		results = append(results, testFile)
 	}
 
+	for _, duplicate := range FindDuplicateTests(results) {
+		origins := make([]string, 0, len(duplicate.Files))
+		for _, file := range duplicate.Files {
+			origins = append(origins, fmt.Sprintf("%s (%s)", file.RelativePath, file.Origin()))
+		}
+		logging.Warnf("Test %s is defined multiple times, results can not be attributed reliably: %s", duplicate.Name, strings.Join(origins, ", "))
+	}
+
 	return results, nil
 }
 
 func parseTestDirectory(directory string, gameIgnoreList []string) ([]*PdxTestFile, error) {
+	return walkTestDirectory(directory, gameIgnoreList, parseTestFile)
+}
+
+// walkTestDirectory calls parse for every scripted test file in the directory.
+// Files for which parse returns nil are skipped.
+func walkTestDirectory(directory string, gameIgnoreList []string, parse func(file string) (*PdxTestFile, error)) ([]*PdxTestFile, error) {
 	tests := make([]*PdxTestFile, 0)
-	err := filepath.WalkDir(directory, func(filepath string, info os.DirEntry, err error) error {
+	_, err := os.Stat(directory)
+	if errors.Is(err, os.ErrNotExist) {
+		// Most mods do not define scripted tests
+		logging.Debugf("No scripted tests directory: %s", directory)
+		return tests, nil
+	}
+	err = filepath.WalkDir(directory, func(path string, info os.DirEntry, err error) error {
 		if err != nil {
 			return err
 		}
@@ -85,14 +168,24 @@ func parseTestDirectory(directory string, gameIgnoreList []string) ([]*PdxTestFi
 			return nil
 		}
 		for _, ignore := range gameIgnoreList {
-			if strings.HasSuffix(filepath, ignore) {
+			if strings.HasSuffix(path, ignore) {
 				return nil
 			}
 		}
-		testsInFile, err := parseTestFile(filepath)
+		testsInFile, err := parse(path)
 		if err != nil {
 			return err
 		}
+		if testsInFile == nil {
+			// Skipped because of a syntax error
+			return nil
+		}
+		relativePath, err := filepath.Rel(directory, path)
+		if err != nil {
+			return err
+		}
+		testsInFile.Root = directory
+		testsInFile.RelativePath = strings.TrimSuffix(relativePath, ignoreSuffix)
 		tests = append(tests, testsInFile)
 		return nil
 	})
@@ -107,20 +200,24 @@ func parseTestFile(file string) (*PdxTestFile, error) {
 	if err != nil {
 		return nil, err
 	}
-	matches := regexTest.FindAllStringSubmatch(string(content), -1)
-	tests := make([]*PdxTest, len(matches))
-
-	lastDate := regexLastDate.FindStringSubmatch(string(content))
-	displayName := regexTestDisplayName.FindStringSubmatch(string(content))
-
-	for i, match := range matches {
-		tests[i] = &PdxTest{
-			Name:        match[3],
-			DisplayName: match[1],
-			Description: match[2],
-		}
+	script, err := ParseScript(file, content)
+	var scriptError *ScriptError
+	if errors.As(err, &scriptError) {
+		// A single broken file must not stop the test run, the lint command reports it as an issue
+		logging.Warnf("Skipping test file with syntax error: %v", scriptError)
+		return nil, nil
 	}
+	if err != nil {
+		return nil, err
+	}
+	return newPdxTestFile(file, script), nil
+}
 
+func newPdxTestFile(file string, script *ScriptFile) *PdxTestFile {
+	tests := make([]*PdxTest, 0)
+	for _, node := range findTestNodes(script.Nodes) {
+		tests = append(tests, newPdxTest(script, node))
+	}
 	if len(tests) <= 0 {
 		logging.Debugf("No tests found in file %s", file)
 	}
@@ -131,14 +228,122 @@ func parseTestFile(file string) (*PdxTestFile, error) {
 		Path:    file,
 		Tests:   tests,
 	}
-	if lastDate != nil {
-		testFile.LastDate = lastDate[1]
+	if lastDate := findNode(script.Nodes, "last_date"); lastDate != nil {
+		testFile.LastDate = lastDate.Value
+	}
+	if header := fileNameAnnotation(script); header != nil {
+		_, testFile.DisplayName = parseAnnotation(header)
+	}
+
+	return testFile
+}
+
+// findTestNodes returns all blocks defining a scripted test.
+// Blocks not defining a test (e.g. wrapping blocks) are searched recursively.
+func findTestNodes(nodes []*ScriptNode) []*ScriptNode {
+	tests := make([]*ScriptNode, 0)
+	for _, node := range nodes {
+		if !node.IsBlock {
+			continue
+		}
+		if node.Key != "" && isTestBlock(node) {
+			tests = append(tests, node)
+			continue
+		}
+		tests = append(tests, findTestNodes(node.Children)...)
 	}
-	if displayName != nil {
-		testFile.DisplayName = displayName[1]
+	return tests
+}
+
+func isTestBlock(node *ScriptNode) bool {
+	for _, key := range testKeys {
+		if node.Child(key) != nil {
+			return true
+		}
 	}
+	return false
+}
 
-	return testFile, nil
+func newPdxTest(script *ScriptFile, node *ScriptNode) *PdxTest {
+	test := &PdxTest{
+		Name:     node.Key,
+		Path:     script.Path,
+		Position: node.Start,
+		Fields:   make(map[string]string),
+		start:    node.Start.Offset,
+		end:      node.End.Offset,
+	}
+	for _, child := range node.Children {
+		switch child.Key {
+		case "acceptable_fail_rate":
+			rate, err := strconv.ParseFloat(child.Value, 64)
+			if err != nil {
+				logging.Warnf("%s:%s: invalid acceptable_fail_rate: %s", script.Path, child.Start, child.Value)
+				continue
+			}
+			test.AcceptableFailRate = rate
+		case "success":
+			test.Success = script.BlockSource(child)
+		case "fail":
+			test.Fail = script.BlockSource(child)
+		default:
+			test.Fields[child.Key] = script.Source(child)
+		}
+	}
+	header := fileNameAnnotation(script)
+	for _, comment := range node.Comments {
+		if comment == header {
+			// Names the whole file and must stay when the test is filtered out
+			continue
+		}
+		if test.start == node.Start.Offset {
+			test.start = comment.Start.Offset
+		}
+		switch annotation, value := parseAnnotation(comment); annotation {
+		case annotationName:
+			test.DisplayName = value
+		case annotationDescription:
+			test.Description = value
+		}
+	}
+	return test
+}
+
+// fileNameAnnotation returns the name annotation at the very start of the file naming the whole file
+func fileNameAnnotation(script *ScriptFile) *ScriptComment {
+	if len(script.Comments) == 0 {
+		return nil
+	}
+	comment := script.Comments[0]
+	if comment.Start.Line != 1 || comment.Start.Column != 1 {
+		return nil
+	}
+	if annotation, _ := parseAnnotation(comment); annotation != annotationName {
+		return nil
+	}
+	return comment
+}
+
+// parseAnnotation returns the kind and value of ### name = ... and ### desc = ... comments
+func parseAnnotation(comment *ScriptComment) (string, string) {
+	match := regexAnnotation.FindStringSubmatch(comment.Text)
+	if match == nil {
+		return "", ""
+	}
+	return match[1], match[2]
+}
+
+// findNode returns the first scalar statement with the given key (depth first)
+func findNode(nodes []*ScriptNode, key string) *ScriptNode {
+	for _, node := range nodes {
+		if node.Key == key && !node.IsBlock {
+			return node
+		}
+		if found := findNode(node.Children, key); found != nil {
+			return found
+		}
+	}
+	return nil
 }
 
 func mergeTestFiles(existingTests []*PdxTestFile, newTests []*PdxTestFile) []*PdxTestFile {
@@ -147,7 +352,8 @@ func mergeTestFiles(existingTests []*PdxTestFile, newTests []*PdxTestFile) []*Pd
 	for _, newFile := range newTests {
 		found := false
 		for exitingIndex, existingFile := range existingTests {
-			if existingFile.Name == newFile.Name {
+			if existingFile.RelativePath == newFile.RelativePath {
+				newFile.Overrides = existingFile
 				mergedFiles[exitingIndex] = newFile
 				found = true
 				break
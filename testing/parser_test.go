@@ -0,0 +1,83 @@
+package testing
+
+import (
+	"os"
+	"path/filepath"
+	gotesting "testing"
+
+	"bahmut.de/pdx-test-runner/game"
+)
+
+func TestParseTestDirectorySkipsBrokenFiles(t *gotesting.T) {
+	directory := t.TempDir()
+	files := map[string]string{
+		"valid.txt":      "test_valid = { success = { always = yes } }",
+		"wip.txt.ignore": "test_wip = { success = {",
+		"broken.txt":     "test_broken = { success = { always = yes } } }",
+	}
+	for name, content := range files {
+		err := os.WriteFile(filepath.Join(directory, name), []byte(content), os.ModePerm)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	testFiles, err := parseTestDirectory(directory, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(testFiles) != 1 || testFiles[0].Name != "valid.txt" {
+		t.Errorf("expected only valid.txt, got %v files", len(testFiles))
+	}
+}
+
+func TestGetTestFilesWithoutModTests(t *gotesting.T) {
+	gameDirectory := t.TempDir()
+	testDirectory := filepath.Join(gameDirectory, "tools", "scripted_tests")
+	err := os.MkdirAll(testDirectory, os.ModePerm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.WriteFile(filepath.Join(testDirectory, "base.txt"), []byte("test_base = { success = { always = yes } }"), os.ModePerm)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Mod without a scripted tests directory
+	testFiles, err := GetTestFiles(gameDirectory, []string{t.TempDir()}, game.Victoria3)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(testFiles) != 1 || testFiles[0].Name != "base.txt" {
+		t.Errorf("expected only base.txt, got %v files", len(testFiles))
+	}
+}
+
+func TestFilterFirstTestKeepsFileHeader(t *gotesting.T) {
+	content := "### name = Economy Tests\n### desc = Has money\ntest_first = { success = { always = yes } }\n\ntest_second = { success = { always = yes } }\n"
+	path := filepath.Join(t.TempDir(), "economy.txt")
+	err := os.WriteFile(path, []byte(content), os.ModePerm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file, err := parseTestFile(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if file.DisplayName != "Economy Tests" || file.Tests[0].DisplayName != "" || file.Tests[0].Description != "Has money" {
+		t.Errorf("unexpected annotations: %q, %q, %q", file.DisplayName, file.Tests[0].DisplayName, file.Tests[0].Description)
+	}
+
+	err = SelectTests([]*PdxTestFile{file}, nil, []string{"test:test_first"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	filtered, err := filteredTestFileContent(file)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := "### name = Economy Tests\n\n\ntest_second = { success = { always = yes } }\n"
+	if string(filtered) != expected {
+		t.Errorf("expected %q, got %q", expected, string(filtered))
+	}
+}
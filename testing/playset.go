@@ -0,0 +1,37 @@
+package testing
+
+import (
+	"bahmut.de/pdx-test-runner/game"
+	"bahmut.de/pdx-test-runner/logging"
+)
+
+// EnableModDirectories enables exactly the mods in the given directories in the given load order,
+// so the game loads them instead of the mods selected in the launcher.
+// The original content load file is backed up in the journal and restored with it.
+func EnableModDirectories(settings *game.LauncherSettings, modDirectories []string, journal *Journal) error {
+	references := make([]string, 0, len(modDirectories))
+	for _, directory := range modDirectories {
+		reference, err := game.ModReference(settings, directory)
+		if err != nil {
+			return err
+		}
+		references = append(references, reference)
+	}
+
+	contentLoad, err := game.ReadContentLoad(settings)
+	if err != nil {
+		return err
+	}
+	err = journal.RecordBackup(contentLoad.Path)
+	if err != nil {
+		return err
+	}
+	contentLoad.SetEnabledMods(references)
+	err = contentLoad.Write()
+	if err != nil {
+		return err
+	}
+	logging.Debugf("Enabled %v configured mods in %s", len(references), contentLoad.Path)
+
+	return nil
+}
@@ -0,0 +1,25 @@
+package testing
+
+import (
+	"errors"
+	"os/exec"
+	"syscall"
+)
+
+// startInProcessGroup starts the game in its own process group,
+// so the game and all of its child processes can be stopped together.
+func startInProcessGroup(binary *exec.Cmd) error {
+	binary.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	return binary.Start()
+}
+
+func killProcessTree(binary *exec.Cmd) error {
+	return syscall.Kill(-binary.Process.Pid, syscall.SIGKILL)
+}
+
+// processRunning reports whether a process with the id exists
+func processRunning(pid int) bool {
+	err := syscall.Kill(pid, 0)
+	// Processes of other users can not be signaled, but exist
+	return err == nil || errors.Is(err, syscall.EPERM)
+}
@@ -0,0 +1,47 @@
+package testing
+
+import (
+	"errors"
+	"os/exec"
+	"strconv"
+	"syscall"
+
+	"golang.org/x/sys/windows"
+)
+
+// Exit code of processes that have not exited yet
+const stillActive = 259
+
+// startInProcessGroup starts the game in a new process group,
+// so a Ctrl+C in the console is only received by the test runner.
+func startInProcessGroup(binary *exec.Cmd) error {
+	binary.SysProcAttr = &syscall.SysProcAttr{CreationFlags: windows.CREATE_NEW_PROCESS_GROUP}
+	return binary.Start()
+}
+
+func killProcessTree(binary *exec.Cmd) error {
+	err := exec.Command("taskkill", "/T", "/F", "/PID", strconv.Itoa(binary.Process.Pid)).Run()
+	if err != nil {
+		// Fall back to only stopping the game itself
+		return binary.Process.Kill()
+	}
+	return nil
+}
+
+// processRunning reports whether a process with the id exists and has not exited yet
+func processRunning(pid int) bool {
+	handle, err := windows.OpenProcess(windows.PROCESS_QUERY_LIMITED_INFORMATION, false, uint32(pid))
+	if errors.Is(err, windows.ERROR_ACCESS_DENIED) {
+		// Processes of other users can not be queried, but exist
+		return true
+	}
+	if err != nil {
+		return false
+	}
+	defer func() {
+		_ = windows.CloseHandle(handle)
+	}()
+	var exitCode uint32
+	err = windows.GetExitCodeProcess(handle, &exitCode)
+	return err == nil && exitCode == stillActive
+}
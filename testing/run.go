@@ -2,6 +2,8 @@ package testing
 
 import (
 	"bufio"
+	"context"
+	"errors"
 	"fmt"
 	"os"
 	"os/exec"
@@ -15,8 +17,12 @@ import (
 	"bahmut.de/pdx-test-runner/logging"
 )
 
-const failTestPrefix = "TEST_FAIL_"
-const resultFileName = "tests.txt"
+// FailTestPrefix starts the name of every save game the game writes when a test fails
+const FailTestPrefix = "TEST_FAIL_"
+
+// ResultFileName is the name of the file the game writes the test results to
+const ResultFileName = "tests.txt"
+
 const saveGameDirectoryName = "save games"
 
 const testResultSuccess = "OK"
@@ -28,12 +34,44 @@ var saveGameSuffixes = map[game.Type]string{
 	game.CrusaderKings3: ".ck3",
 }
 
+// Outcome describes how the game process of a test run ended
+type Outcome int
+
+const (
+	OutcomeCompleted   Outcome = iota // All tests finished
+	OutcomeTimedOut                   // The overall timeout was reached
+	OutcomeStalled                    // The result file did not change for too long
+	OutcomeGameExited                 // The game exited on its own before the tests finished
+	OutcomeInterrupted                // The test run was interrupted by the user
+)
+
+func (outcome Outcome) String() string {
+	switch outcome {
+	case OutcomeCompleted:
+		return "Completed"
+	case OutcomeTimedOut:
+		return "Timed Out"
+	case OutcomeStalled:
+		return "Stalled"
+	case OutcomeGameExited:
+		return "Game Exited"
+	case OutcomeInterrupted:
+		return "Interrupted"
+	default:
+		return "Unknown"
+	}
+}
+
 type ExecutionResults struct {
+	Outcome         Outcome
 	OutputDirectory string
 	TestResults     []*TestResult
+	SaveGames       []string
 	StartTime       time.Time
 	EndTime         time.Time
 	Duration        time.Duration
+	Baseline        string      // Output directory of the run the results are compared with (empty if none)
+	Diff            []*TestDiff // Changes compared to the baseline run (nil without baseline)
 }
 
 type TestResult struct {
@@ -43,8 +81,10 @@ type TestResult struct {
 	TestFile *PdxTestFile
 }
 
-func RunTests(settings *game.LauncherSettings, config *config.TestRunnerConfig, testFiles []*PdxTestFile) (*ExecutionResults, error) {
-	resultFile := filepath.Join(settings.DataPath, resultFileName)
+// RunTests runs the game until all tests finished.
+// Cancelling the context stops the game and returns the results collected so far.
+func RunTests(ctx context.Context, settings *game.LauncherSettings, config *config.TestRunnerConfig, testFiles []*PdxTestFile) (*ExecutionResults, error) {
+	resultFile := filepath.Join(settings.DataPath, ResultFileName)
 
 	// Delete old test results
 	err := deleteTestResults(resultFile)
@@ -53,7 +93,9 @@ func RunTests(settings *game.LauncherSettings, config *config.TestRunnerConfig,
 	}
 
 	startTime := time.Now()
-	err = runGame(settings.ExecPath, resultFile)
+	expectedTests := activeTestNames(testFiles)
+	stream := newResultStream(resultFile, "", expectedTests, newResultProgress(len(expectedTests)))
+	outcome, err := runGame(ctx, gameCommand(settings.ExecPath), stream, config)
 	if err != nil {
 		return nil, err
 	}
@@ -64,6 +106,7 @@ func RunTests(settings *game.LauncherSettings, config *config.TestRunnerConfig,
 		return nil, err
 	}
 
+	results.Outcome = outcome
 	results.StartTime = startTime
 	results.EndTime = endTime
 	results.Duration = endTime.Sub(startTime)
@@ -81,52 +124,141 @@ func deleteTestResults(resultFile string) error {
 	return nil
 }
 
-func runGame(gameBinary, resultFile string) error {
-	binary := exec.Command(gameBinary, "-nographics", "-handsoff", "-scripted_tests")
-	err := binary.Start()
+// gameCommand returns the command running the scripted tests headless with additional arguments
+func gameCommand(gameBinary string, arguments ...string) *exec.Cmd {
+	return exec.Command(gameBinary, append([]string{"-nographics", "-handsoff", "-scripted_tests"}, arguments...)...)
+}
+
+// runGame runs the game until all tests of the result stream finished.
+// Every result is reported as soon as the game writes it.
+func runGame(ctx context.Context, binary *exec.Cmd, stream *resultStream, config *config.TestRunnerConfig) (Outcome, error) {
+	if ctx.Err() != nil {
+		return OutcomeInterrupted, nil
+	}
+
+	err := startInProcessGroup(binary)
 	if err != nil {
-		return fmt.Errorf("error starting game: %v", err)
+		return OutcomeCompleted, fmt.Errorf("error starting game: %v", err)
 	}
 
-	done := false
-	for !done {
-		time.Sleep(30 * time.Second)
-		if _, err := os.Stat(resultFile); os.IsNotExist(err) {
-			continue
-		}
-		content, err := os.ReadFile(resultFile)
+	exited := make(chan error, 1)
+	go func() {
+		exited <- binary.Wait()
+	}()
+
+	timeout := time.Duration(config.TimeoutMinutes) * time.Minute
+	stallTimeout := time.Duration(config.StallTimeoutMinutes) * time.Minute
+	gracePeriod := time.Duration(config.GracePeriodSeconds) * time.Second
+	// Polling also checks the timeouts and catches changes missed by the watcher
+	ticker := time.NewTicker(time.Duration(config.PollIntervalSeconds) * time.Second)
+	defer ticker.Stop()
+	watcher := watchResultFile(stream.resultFile, config)
+	defer func() {
+		err := watcher.Close()
 		if err != nil {
-			return fmt.Errorf("could not check test results: %v", err)
+			logging.Errorf("Could not stop watching test results: %v", err)
+		}
+	}()
+
+	startTime := time.Now()
+	lastProgress := startTime
+	var lastSize int64
+	var lastModified time.Time
+	for {
+		select {
+		case err := <-exited:
+			// the game may have closed itself after writing all results
+			_ = stream.read()
+			if stream.finished() {
+				return OutcomeCompleted, nil
+			}
+			if err != nil {
+				logging.Errorf("Game exited before tests finished: %v", err)
+			} else {
+				logging.Error("Game exited before tests finished")
+			}
+			return OutcomeGameExited, nil
+		case <-ctx.Done():
+			logging.Warn("Test run interrupted, stopping game")
+			return OutcomeInterrupted, stopGame(binary, exited, 0)
+		case <-ticker.C:
+		case <-watcher.changes:
+		}
+
+		now := time.Now()
+		if info, err := os.Stat(stream.resultFile); err == nil {
+			if info.Size() != lastSize || !info.ModTime().Equal(lastModified) {
+				lastSize = info.Size()
+				lastModified = info.ModTime()
+				lastProgress = now
+			}
+			err = stream.read()
+			if err != nil {
+				_ = stopGame(binary, exited, 0)
+				return OutcomeCompleted, err
+			}
+			if stream.finished() {
+				return OutcomeCompleted, stopGame(binary, exited, gracePeriod)
+			}
+		}
+		if timeout > 0 && now.Sub(startTime) >= timeout {
+			logging.Errorf("Test run timed out after %s", timeout)
+			return OutcomeTimedOut, stopGame(binary, exited, gracePeriod)
 		}
-		// when tests are finished they are logged with [ OK ] or [ FAIL ]
-		if strings.ContainsAny(string(content), "[]") {
-			done = true
+		if stallTimeout > 0 && now.Sub(lastProgress) >= stallTimeout {
+			logging.Errorf("Test run made no progress for %s", stallTimeout)
+			return OutcomeStalled, stopGame(binary, exited, gracePeriod)
 		}
 	}
+}
 
-	err = binary.Process.Kill()
+// stopGame kills the game after waiting for the grace period,
+// so the game has time to flush its remaining output.
+func stopGame(binary *exec.Cmd, exited <-chan error, gracePeriod time.Duration) error {
+	select {
+	case <-exited:
+		return nil
+	case <-time.After(gracePeriod):
+	}
+	err := killProcessTree(binary)
 	if err != nil {
 		return fmt.Errorf("error stopping game: %v", err)
 	}
+	<-exited
 	return nil
 }
 
+// activeTestNames returns the names of all tests the game is expected to run
+func activeTestNames(testFiles []*PdxTestFile) map[string]bool {
+	names := make(map[string]bool)
+	for _, file := range testFiles {
+		for _, test := range file.Tests {
+			if file.IsTestActive(test) {
+				names[test.Name] = true
+			}
+		}
+	}
+	return names
+}
+
 func collectTestResults(resultFile string, settings *game.LauncherSettings, config *config.TestRunnerConfig, testFiles []*PdxTestFile) (*ExecutionResults, error) {
 	saveDirectory := filepath.Join(settings.DataPath, saveGameDirectoryName)
 
-	// create output directory
-	runOutputDirectory := filepath.Join(config.OutputDirectory, time.Now().Format("2006-01-02_15_04_05"))
-	if _, err := os.Stat(runOutputDirectory); os.IsNotExist(err) {
-		err = os.MkdirAll(runOutputDirectory, os.ModePerm)
-		if err != nil {
-			return nil, fmt.Errorf("error creating test result output directory: %v", err)
-		}
+	runOutputDirectory, err := createRunOutputDirectory(config)
+	if err != nil {
+		return nil, err
 	}
 	if _, err := os.Stat(saveDirectory); os.IsNotExist(err) {
 		return nil, fmt.Errorf("save game directory does not exist: %s", saveDirectory)
 	}
 	if _, err := os.Stat(resultFile); os.IsNotExist(err) {
-		return nil, fmt.Errorf("test result file does not exist: %s", resultFile)
+		// The game may have crashed or hung before writing any results
+		logging.Errorf("Test result file does not exist: %s", resultFile)
+		return &ExecutionResults{
+			OutputDirectory: runOutputDirectory,
+			TestResults:     make([]*TestResult, 0),
+			SaveGames:       make([]string, 0),
+		}, nil
 	}
 
 	// Move test result file to output directory
@@ -140,19 +272,60 @@ func collectTestResults(resultFile string, settings *game.LauncherSettings, conf
 		return nil, fmt.Errorf("could not copy test result file to output directory: %v", err)
 	}
 
-	// Move test fail save games to output directory
-	err = filepath.WalkDir(saveDirectory, func(file string, info os.DirEntry, err error) error {
+	saveGames, err := collectSaveGames(saveDirectory, runOutputDirectory, settings.GameType, config.MoveSaveGames)
+	if err != nil {
+		return nil, err
+	}
+
+	testResults, err := parseTestResults(resultFile, testFiles)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ExecutionResults{
+		OutputDirectory: runOutputDirectory,
+		TestResults:     testResults,
+		SaveGames:       saveGames,
+	}, nil
+}
+
+// createRunOutputDirectory creates the output directory of a single test run.
+// Runs started within the same second get a numbered suffix, so they never share a directory.
+func createRunOutputDirectory(config *config.TestRunnerConfig) (string, error) {
+	err := os.MkdirAll(config.OutputDirectory, os.ModePerm)
+	if err != nil {
+		return "", fmt.Errorf("error creating test result output directory: %v", err)
+	}
+	name := time.Now().Format("2006-01-02_15_04_05")
+	runOutputDirectory := filepath.Join(config.OutputDirectory, name)
+	for number := 2; ; number++ {
+		err = os.Mkdir(runOutputDirectory, os.ModePerm)
+		if !errors.Is(err, os.ErrExist) {
+			break
+		}
+		runOutputDirectory = filepath.Join(config.OutputDirectory, fmt.Sprintf("%s_%v", name, number))
+	}
+	if err != nil {
+		return "", fmt.Errorf("error creating test result output directory: %v", err)
+	}
+	return runOutputDirectory, nil
+}
+
+// collectSaveGames copies (or moves) all test fail save games to the output directory
+func collectSaveGames(saveDirectory, runOutputDirectory string, gameType game.Type, move bool) ([]string, error) {
+	saveGames := make([]string, 0)
+	err := filepath.WalkDir(saveDirectory, func(file string, info os.DirEntry, err error) error {
 		if err != nil {
 			return err
 		}
 		if info.IsDir() {
 			return nil
 		}
-		if !strings.HasSuffix(info.Name(), saveGameSuffixes[settings.GameType]) {
+		if !strings.HasSuffix(info.Name(), saveGameSuffixes[gameType]) {
 			// Ignore non save game files
 			return nil
 		}
-		if !strings.HasPrefix(info.Name(), failTestPrefix) {
+		if !strings.HasPrefix(info.Name(), FailTestPrefix) {
 			// We only care about test fail save games
 			return nil
 		}
@@ -165,7 +338,8 @@ func collectTestResults(resultFile string, settings *game.LauncherSettings, conf
 		if err != nil {
 			return fmt.Errorf("could not write test result save game to output directory: %v", err)
 		}
-		if config.MoveSaveGames {
+		saveGames = append(saveGames, info.Name())
+		if move {
 			err = os.Remove(file)
 			if err != nil {
 				return fmt.Errorf("could not remove test result save game: %v", err)
@@ -176,16 +350,7 @@ func collectTestResults(resultFile string, settings *game.LauncherSettings, conf
 	if err != nil {
 		return nil, err
 	}
-
-	testResults, err := parseTestResults(resultFile, testFiles)
-	if err != nil {
-		return nil, err
-	}
-
-	return &ExecutionResults{
-		OutputDirectory: runOutputDirectory,
-		TestResults:     testResults,
-	}, nil
+	return saveGames, nil
 }
 
 func parseTestResults(resultFile string, testFiles []*PdxTestFile) ([]*TestResult, error) {
@@ -208,11 +373,14 @@ func parseTestResults(resultFile string, testFiles []*PdxTestFile) ([]*TestResul
 		if matches == nil {
 			continue
 		}
-		testFile, test := getTestFileAndTestByName(matches[2], testFiles)
+		testFile, test, ambiguous := getTestFileAndTestByName(matches[2], testFiles)
 		if testFile == nil || test == nil {
 			logging.Errorf("Could not match test result (%s) to parsed tests: %s", matches[2], line)
 			continue
 		}
+		if ambiguous {
+			logging.Warnf("Test result (%s) is ambiguous and attributed to %s (%s)", test.Name, testFile.RelativePath, testFile.Origin())
+		}
 		testResult := &TestResult{}
 		if matches[1] == testResultSuccess {
 			testResult.Success = true
@@ -229,13 +397,49 @@ func parseTestResults(resultFile string, testFiles []*PdxTestFile) ([]*TestResul
 	return results, nil
 }
 
-func getTestFileAndTestByName(name string, testFiles []*PdxTestFile) (*PdxTestFile, *PdxTest) {
+// getTestFileAndTestByName returns the test a result with the given name belongs to.
+// Active tests are preferred, since the game only runs those.
+// The result is ambiguous if multiple active tests have the name.
+func getTestFileAndTestByName(name string, testFiles []*PdxTestFile) (*PdxTestFile, *PdxTest, bool) {
+	var inactiveFile, activeFile *PdxTestFile
+	var inactiveTest, activeTest *PdxTest
+	activeCount := 0
+	for _, file := range testFiles {
+		for _, test := range file.Tests {
+			if test.Name != name {
+				continue
+			}
+			if !file.IsTestActive(test) {
+				if inactiveTest == nil {
+					inactiveFile, inactiveTest = file, test
+				}
+				continue
+			}
+			if activeTest == nil {
+				activeFile, activeTest = file, test
+			}
+			activeCount++
+		}
+	}
+	if activeTest == nil {
+		return inactiveFile, inactiveTest, false
+	}
+	return activeFile, activeTest, activeCount > 1
+}
+
+// MissingTests returns all active tests that have no test result.
+func MissingTests(results *ExecutionResults, testFiles []*PdxTestFile) []*PdxTest {
+	found := make(map[*PdxTest]bool)
+	for _, result := range results.TestResults {
+		found[result.Test] = true
+	}
+	missing := make([]*PdxTest, 0)
 	for _, file := range testFiles {
 		for _, test := range file.Tests {
-			if test.Name == name {
-				return file, test
+			if file.IsTestActive(test) && !found[test] {
+				missing = append(missing, test)
 			}
 		}
 	}
-	return nil, nil
+	return missing
 }
@@ -0,0 +1,58 @@
+package testing
+
+import (
+	gotesting "testing"
+
+	"bahmut.de/pdx-test-runner/config"
+)
+
+func TestGetTestFileAndTestByName(t *gotesting.T) {
+	ignoredTest := &PdxTest{Name: "test_duplicate"}
+	ignoredFile := &PdxTestFile{Name: "ignored.txt", Ignored: true, Tests: []*PdxTest{ignoredTest}}
+	activeTest := &PdxTest{Name: "test_duplicate"}
+	activeFile := &PdxTestFile{Name: "active.txt", Tests: []*PdxTest{activeTest}}
+	otherTest := &PdxTest{Name: "test_duplicate"}
+	otherFile := &PdxTestFile{Name: "other.txt", Tests: []*PdxTest{otherTest}}
+	unselectedTest := &PdxTest{Name: "test_unselected", Ignored: true}
+	unselectedFile := &PdxTestFile{Name: "unselected.txt", Tests: []*PdxTest{unselectedTest}}
+
+	tests := []struct {
+		name      string
+		result    string
+		testFiles []*PdxTestFile
+		test      *PdxTest
+		ambiguous bool
+	}{
+		{"prefers active test", "test_duplicate", []*PdxTestFile{ignoredFile, activeFile}, activeTest, false},
+		{"multiple active tests", "test_duplicate", []*PdxTestFile{ignoredFile, activeFile, otherFile}, activeTest, true},
+		{"only inactive test", "test_duplicate", []*PdxTestFile{ignoredFile}, ignoredTest, false},
+		{"unselected test", "test_unselected", []*PdxTestFile{unselectedFile}, unselectedTest, false},
+		{"unknown test", "test_unknown", []*PdxTestFile{activeFile}, nil, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *gotesting.T) {
+			_, actual, ambiguous := getTestFileAndTestByName(test.result, test.testFiles)
+			if actual != test.test {
+				t.Errorf("result attributed to the wrong test")
+			}
+			if ambiguous != test.ambiguous {
+				t.Errorf("expected ambiguous %v, got %v", test.ambiguous, ambiguous)
+			}
+		})
+	}
+}
+
+func TestCreateRunOutputDirectoryIsUnique(t *gotesting.T) {
+	testConfig := &config.TestRunnerConfig{OutputDirectory: t.TempDir()}
+	first, err := createRunOutputDirectory(testConfig)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	second, err := createRunOutputDirectory(testConfig)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if first == second {
+		t.Errorf("runs share the output directory %s", first)
+	}
+}
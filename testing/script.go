@@ -0,0 +1,405 @@
+package testing
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ScriptPosition is a position in a script file.
+// Lines and columns start at 1, columns count characters.
+type ScriptPosition struct {
+	Offset int
+	Line   int
+	Column int
+}
+
+func (position ScriptPosition) String() string {
+	return fmt.Sprintf("%d:%d", position.Line, position.Column)
+}
+
+// ScriptError is a syntax error in a script file
+type ScriptError struct {
+	Path     string
+	Position ScriptPosition
+	Message  string
+}
+
+func (err *ScriptError) Error() string {
+	return fmt.Sprintf("%s:%s: %s", err.Path, err.Position, err.Message)
+}
+
+// ScriptComment is a comment including its leading '#' characters
+type ScriptComment struct {
+	Text  string
+	Start ScriptPosition
+	End   ScriptPosition
+}
+
+// ScriptNode is a statement of a Paradox (Clausewitz/Jomini) script file.
+//
+// A statement is either an assignment (key = value, key = { ... }),
+// a comparison (key > value) or a bare value inside a block (e.g. lists like { a b c }).
+// Bare values have an empty Key and Operator.
+type ScriptNode struct {
+	Key      string
+	Operator string
+	Value    string // Scalar value, or the type of typed blocks (e.g. rgb { ... })
+	Quoted   bool   // Whether the scalar value was quoted
+	IsBlock  bool
+	Children []*ScriptNode
+	Comments []*ScriptComment // Own-line comments directly above the statement
+	Start    ScriptPosition
+	End      ScriptPosition // Position after the last character of the statement
+}
+
+// Child returns the first direct child with the given key
+func (node *ScriptNode) Child(key string) *ScriptNode {
+	for _, child := range node.Children {
+		if child.Key == key {
+			return child
+		}
+	}
+	return nil
+}
+
+// ScriptFile is the syntax tree of a script file
+type ScriptFile struct {
+	Path     string
+	Nodes    []*ScriptNode
+	Comments []*ScriptComment // All comments of the file
+	content  []byte
+}
+
+// Source returns the unmodified source of the statement
+func (file *ScriptFile) Source(node *ScriptNode) string {
+	return string(file.content[node.Start.Offset:node.End.Offset])
+}
+
+// BlockSource returns the source between the braces of a block statement without common indentation
+func (file *ScriptFile) BlockSource(node *ScriptNode) string {
+	if !node.IsBlock {
+		return node.Value
+	}
+	source := file.Source(node)
+	source = source[strings.IndexByte(source, '{')+1 : strings.LastIndexByte(source, '}')]
+	lines := strings.Split(strings.Trim(source, "\r\n"), "\n")
+	indentation := -1
+	for _, line := range lines {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		lineIndentation := len(line) - len(strings.TrimLeft(line, " \t"))
+		if indentation < 0 || lineIndentation < indentation {
+			indentation = lineIndentation
+		}
+	}
+	for i, line := range lines {
+		if len(line) >= indentation && indentation > 0 {
+			lines[i] = line[indentation:]
+		}
+		lines[i] = strings.TrimRight(lines[i], " \t\r")
+	}
+	return strings.TrimSpace(strings.Join(lines, "\n"))
+}
+
+type scriptTokenKind int
+
+const (
+	tokenScalar scriptTokenKind = iota
+	tokenString
+	tokenOperator
+	tokenOpenBrace
+	tokenCloseBrace
+	tokenComment
+	tokenEnd
+)
+
+type scriptToken struct {
+	kind  scriptTokenKind
+	text  string
+	start ScriptPosition
+	end   ScriptPosition
+}
+
+// Characters ending an unquoted scalar
+const scriptSpecialCharacters = "{}=<>!?#\""
+
+type scriptLexer struct {
+	path     string
+	content  []byte
+	position ScriptPosition
+}
+
+func (lexer *scriptLexer) errorf(position ScriptPosition, format string, v ...any) error {
+	return &ScriptError{Path: lexer.path, Position: position, Message: fmt.Sprintf(format, v...)}
+}
+
+func (lexer *scriptLexer) peekByte(offset int) byte {
+	index := lexer.position.Offset + offset
+	if index >= len(lexer.content) {
+		return 0
+	}
+	return lexer.content[index]
+}
+
+func (lexer *scriptLexer) advance() {
+	character := lexer.content[lexer.position.Offset]
+	lexer.position.Offset++
+	if character == '\n' {
+		lexer.position.Line++
+		lexer.position.Column = 1
+	} else if character&0xC0 != 0x80 {
+		// Only count the first byte of multibyte characters
+		lexer.position.Column++
+	}
+}
+
+func (lexer *scriptLexer) tokenize() ([]*scriptToken, error) {
+	lexer.position = ScriptPosition{Line: 1, Column: 1}
+	// Skip UTF-8 byte order mark
+	if strings.HasPrefix(string(lexer.content), "\xEF\xBB\xBF") {
+		lexer.position.Offset = 3
+	}
+
+	tokens := make([]*scriptToken, 0)
+	for {
+		for lexer.position.Offset < len(lexer.content) && strings.IndexByte(" \t\r\n", lexer.peekByte(0)) >= 0 {
+			lexer.advance()
+		}
+		start := lexer.position
+		if start.Offset >= len(lexer.content) {
+			tokens = append(tokens, &scriptToken{kind: tokenEnd, start: start, end: start})
+			return tokens, nil
+		}
+
+		var kind scriptTokenKind
+		character := lexer.peekByte(0)
+		switch {
+		case character == '#':
+			kind = tokenComment
+			for lexer.position.Offset < len(lexer.content) && lexer.peekByte(0) != '\n' {
+				lexer.advance()
+			}
+		case character == '{':
+			kind = tokenOpenBrace
+			lexer.advance()
+		case character == '}':
+			kind = tokenCloseBrace
+			lexer.advance()
+		case character == '"':
+			kind = tokenString
+			lexer.advance()
+			for {
+				if lexer.position.Offset >= len(lexer.content) {
+					return nil, lexer.errorf(start, "unterminated string")
+				}
+				current := lexer.peekByte(0)
+				lexer.advance()
+				if current == '\\' && lexer.position.Offset < len(lexer.content) {
+					lexer.advance()
+				} else if current == '"' {
+					break
+				}
+			}
+		case strings.IndexByte("=<>!?", character) >= 0:
+			kind = tokenOperator
+			lexer.advance()
+			if lexer.peekByte(0) == '=' {
+				lexer.advance()
+			} else if character == '!' || character == '?' {
+				return nil, lexer.errorf(start, "unexpected character '%c'", character)
+			}
+		case character == '@' && lexer.peekByte(1) == '[':
+			// Inline math (e.g. @[value * 2]) is a single scalar
+			kind = tokenScalar
+			for lexer.position.Offset < len(lexer.content) && lexer.peekByte(0) != ']' {
+				lexer.advance()
+			}
+			if lexer.position.Offset >= len(lexer.content) {
+				return nil, lexer.errorf(start, "unterminated inline math")
+			}
+			lexer.advance()
+		default:
+			kind = tokenScalar
+			for lexer.position.Offset < len(lexer.content) {
+				current := lexer.peekByte(0)
+				if strings.IndexByte(" \t\r\n", current) >= 0 || strings.IndexByte(scriptSpecialCharacters, current) >= 0 {
+					break
+				}
+				lexer.advance()
+			}
+		}
+		tokens = append(tokens, &scriptToken{
+			kind:  kind,
+			text:  string(lexer.content[start.Offset:lexer.position.Offset]),
+			start: start,
+			end:   lexer.position,
+		})
+	}
+}
+
+type scriptParser struct {
+	lexer   *scriptLexer
+	tokens  []*scriptToken
+	index   int
+	file    *ScriptFile
+	pending []*ScriptComment // Own-line comments since the last token
+	leading []*ScriptComment // Comments directly above the last returned token
+	line    int              // Line of the last token
+}
+
+// ParseScript parses the content of a Paradox script file into a syntax tree
+func ParseScript(path string, content []byte) (*ScriptFile, error) {
+	lexer := &scriptLexer{path: path, content: content}
+	tokens, err := lexer.tokenize()
+	if err != nil {
+		return nil, err
+	}
+	parser := &scriptParser{
+		lexer:  lexer,
+		tokens: tokens,
+		file: &ScriptFile{
+			Path:     path,
+			Nodes:    make([]*ScriptNode, 0),
+			Comments: make([]*ScriptComment, 0),
+			content:  content,
+		},
+	}
+	nodes, end, err := parser.parseStatements(nil)
+	if err != nil {
+		return nil, err
+	}
+	if end.kind != tokenEnd {
+		return nil, lexer.errorf(end.start, "unexpected '}' without matching '{'")
+	}
+	parser.file.Nodes = nodes
+	return parser.file, nil
+}
+
+// next returns the next token that is not a comment.
+// Comments are collected and own-line comments directly above the token are kept in leading.
+func (parser *scriptParser) next() *scriptToken {
+	for {
+		token := parser.tokens[parser.index]
+		if token.kind != tokenEnd {
+			parser.index++
+		}
+		if token.kind != tokenComment {
+			parser.leading = nil
+			if len(parser.pending) > 0 && parser.pending[len(parser.pending)-1].End.Line == token.start.Line-1 {
+				parser.leading = parser.pending
+			}
+			parser.pending = nil
+			parser.line = token.end.Line
+			return token
+		}
+
+		comment := &ScriptComment{Text: token.text, Start: token.start, End: token.end}
+		parser.file.Comments = append(parser.file.Comments, comment)
+		switch {
+		case parser.index > 1 && token.start.Line == parser.line:
+			// Trailing comment behind a statement
+			parser.pending = nil
+		case len(parser.pending) > 0 && parser.pending[len(parser.pending)-1].End.Line != token.start.Line-1:
+			// Separated by an empty line
+			parser.pending = []*ScriptComment{comment}
+		default:
+			parser.pending = append(parser.pending, comment)
+		}
+		parser.line = token.end.Line
+	}
+}
+
+func (parser *scriptParser) peek() *scriptToken {
+	for index := parser.index; index < len(parser.tokens); index++ {
+		if parser.tokens[index].kind != tokenComment {
+			return parser.tokens[index]
+		}
+	}
+	return parser.tokens[len(parser.tokens)-1]
+}
+
+// parseStatements parses statements until a closing brace or the end of the file.
+// It returns the parsed statements and the token ending them.
+func (parser *scriptParser) parseStatements(open *scriptToken) ([]*ScriptNode, *scriptToken, error) {
+	nodes := make([]*ScriptNode, 0)
+	for {
+		token := parser.next()
+		comments := parser.leading
+		switch token.kind {
+		case tokenEnd:
+			if open != nil {
+				return nil, nil, parser.lexer.errorf(open.start, "'{' is never closed")
+			}
+			return nodes, token, nil
+		case tokenCloseBrace:
+			return nodes, token, nil
+		case tokenOperator:
+			return nil, nil, parser.lexer.errorf(token.start, "unexpected operator '%s' without key", token.text)
+		case tokenOpenBrace:
+			// Anonymous block inside a list of blocks
+			node := &ScriptNode{Comments: comments, Start: token.start}
+			err := parser.parseBlock(node, token)
+			if err != nil {
+				return nil, nil, err
+			}
+			nodes = append(nodes, node)
+		default:
+			node := &ScriptNode{Comments: comments, Start: token.start}
+			if parser.peek().kind == tokenOperator {
+				node.Key = unquote(token)
+				node.Operator = parser.next().text
+				err := parser.parseValue(node)
+				if err != nil {
+					return nil, nil, err
+				}
+			} else {
+				// Bare value inside a list
+				node.Value = unquote(token)
+				node.Quoted = token.kind == tokenString
+				node.End = token.end
+			}
+			nodes = append(nodes, node)
+		}
+	}
+}
+
+func (parser *scriptParser) parseValue(node *ScriptNode) error {
+	token := parser.next()
+	switch token.kind {
+	case tokenOpenBrace:
+		return parser.parseBlock(node, token)
+	case tokenScalar, tokenString:
+		node.Value = unquote(token)
+		node.Quoted = token.kind == tokenString
+		node.End = token.end
+		if token.kind == tokenScalar && parser.peek().kind == tokenOpenBrace && parser.peek().start.Line == token.end.Line {
+			// Typed block like rgb { 255 0 0 }
+			return parser.parseBlock(node, parser.next())
+		}
+		return nil
+	case tokenEnd:
+		return parser.lexer.errorf(token.start, "missing value for '%s' at end of file", node.Key)
+	default:
+		return parser.lexer.errorf(token.start, "missing value for '%s', found '%s'", node.Key, token.text)
+	}
+}
+
+func (parser *scriptParser) parseBlock(node *ScriptNode, open *scriptToken) error {
+	children, end, err := parser.parseStatements(open)
+	if err != nil {
+		return err
+	}
+	node.IsBlock = true
+	node.Children = children
+	node.End = end.end
+	return nil
+}
+
+func unquote(token *scriptToken) string {
+	if token.kind != tokenString {
+		return token.text
+	}
+	text := token.text[1 : len(token.text)-1]
+	return strings.ReplaceAll(text, `\"`, `"`)
+}
@@ -0,0 +1,183 @@
+package testing
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	gotesting "testing"
+)
+
+// describeNodes returns a compact form of the syntax tree for comparisons,
+// e.g. "a=1 b={c>2 d} e=rgb{255 0 0}"
+func describeNodes(nodes []*ScriptNode) string {
+	parts := make([]string, 0, len(nodes))
+	for _, node := range nodes {
+		part := node.Key + node.Operator + node.Value
+		if node.Quoted {
+			part = node.Key + node.Operator + `"` + node.Value + `"`
+		}
+		if node.IsBlock {
+			part += "{" + describeNodes(node.Children) + "}"
+		}
+		parts = append(parts, part)
+	}
+	return strings.Join(parts, " ")
+}
+
+func TestParseScript(t *gotesting.T) {
+	tests := []struct {
+		name     string
+		content  string
+		expected string
+	}{
+		{"assignments", "a = 1\nb = yes", "a=1 b=yes"},
+		{"comparisons", "a > 1 b <= 2 c != 3 d ?= 4", "a>1 b<=2 c!=3 d?=4"},
+		{"quoted values", `a = "hello world" b = "say \"hi\""`, `a="hello world" b="say "hi""`},
+		{"comments", "# comment\na = 1 # trailing { \n# b = 2\n", "a=1"},
+		{"nested blocks", "a = { b = { c = { d = 1 } } e = 2 }", "a={b={c={d=1}} e=2}"},
+		{"lists", "a = { x y \"z\" }", `a={x y "z"}`},
+		{"anonymous blocks", "a = { { b = 1 } { c = 2 } }", "a={{b=1} {c=2}}"},
+		{"typed blocks", "color = rgb { 255 0 0 }\nb = hsv{ 1 1 1 }", "color=rgb{255 0 0} b=hsv{1 1 1}"},
+		{"value before block on next line", "a = b\n{ c = 1 }", "a=b {c=1}"},
+		{"inline math", "a = @[x * 2] b = @[ (y + 1) / 2 ]", "a=@[x * 2] b=@[ (y + 1) / 2 ]"},
+		{"byte order mark", "\xEF\xBB\xBFa = 1", "a=1"},
+		{"empty file", "", ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *gotesting.T) {
+			script, err := ParseScript("test.txt", []byte(test.content))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if actual := describeNodes(script.Nodes); actual != test.expected {
+				t.Errorf("expected %q, got %q", test.expected, actual)
+			}
+		})
+	}
+}
+
+func TestParseScriptComments(t *gotesting.T) {
+	content := "# file comment\n\n# first\n# second\na = 1 # trailing\n\n# separated\n\nb = 2\n"
+	script, err := ParseScript("test.txt", []byte(content))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(script.Comments) != 5 {
+		t.Errorf("expected 5 comments, got %v", len(script.Comments))
+	}
+	leading := script.Nodes[0].Comments
+	if len(leading) != 2 || leading[0].Text != "# first" || leading[1].Text != "# second" {
+		t.Errorf("unexpected leading comments of a: %v", leading)
+	}
+	if len(script.Nodes[1].Comments) != 0 {
+		t.Errorf("comment separated by an empty line must not belong to b: %v", script.Nodes[1].Comments)
+	}
+}
+
+func TestParseScriptPositions(t *gotesting.T) {
+	script, err := ParseScript("test.txt", []byte("\xEF\xBB\xBFa = 1\n  ä = { b = 2 }\n"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	tests := []struct {
+		node     *ScriptNode
+		expected string
+	}{
+		{script.Nodes[0], "1:1"},
+		{script.Nodes[1], "2:3"},
+		{script.Nodes[1].Children[0], "2:9"},
+	}
+	for _, test := range tests {
+		if actual := test.node.Start.String(); actual != test.expected {
+			t.Errorf("expected %s at %s, got %s", test.node.Key, test.expected, actual)
+		}
+	}
+	if source := script.Source(script.Nodes[1]); source != "ä = { b = 2 }" {
+		t.Errorf("unexpected source: %q", source)
+	}
+}
+
+func TestParseScriptErrors(t *gotesting.T) {
+	tests := []struct {
+		name     string
+		content  string
+		position string
+		message  string
+	}{
+		{"unclosed block", "a = {\n  b = 1\n", "1:5", "'{' is never closed"},
+		{"unclosed nested block", "a = {\n  b = { c = 1\n", "2:7", "'{' is never closed"},
+		{"unmatched brace", "a = 1\n}", "2:1", "unexpected '}' without matching '{'"},
+		{"unterminated string", "a = \"abc\nb = 1", "1:5", "unterminated string"},
+		{"unterminated inline math", "a = @[x * 2", "1:5", "unterminated inline math"},
+		{"missing value", "a = }", "1:5", "missing value for 'a', found '}'"},
+		{"missing value at end", "a =", "1:4", "missing value for 'a' at end of file"},
+		{"operator without key", "= 1", "1:1", "unexpected operator '=' without key"},
+		{"invalid operator", "a ! 1", "1:3", "unexpected character '!'"},
+		{"position after byte order mark", "\xEF\xBB\xBF}", "1:1", "unexpected '}' without matching '{'"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *gotesting.T) {
+			_, err := ParseScript("test.txt", []byte(test.content))
+			var scriptError *ScriptError
+			if !errors.As(err, &scriptError) {
+				t.Fatalf("expected script error, got %v", err)
+			}
+			if actual := scriptError.Position.String(); actual != test.position {
+				t.Errorf("expected error at %s, got %s", test.position, actual)
+			}
+			if scriptError.Message != test.message {
+				t.Errorf("expected message %q, got %q", test.message, scriptError.Message)
+			}
+		})
+	}
+}
+
+func TestParseTestFile(t *gotesting.T) {
+	content := `### name = Economy Tests
+last_date = 1837.1.1
+
+wrapper = {
+	### name = Has Money
+	### desc = The player has money
+	test_money = {
+		success = {
+			money > 0
+		}
+		acceptable_fail_rate = 0.5
+	}
+}
+# Keys in any order
+test_reordered = {
+	fail = { always = no }
+	acceptable_fail_rate = 0
+	success = { always = yes }
+}
+not_a_test = { value = 1 }
+`
+	path := filepath.Join(t.TempDir(), "economy.txt")
+	err := os.WriteFile(path, []byte(content), os.ModePerm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file, err := parseTestFile(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if file.DisplayName != "Economy Tests" || file.LastDate != "1837.1.1" {
+		t.Errorf("unexpected file attributes: %q, %q", file.DisplayName, file.LastDate)
+	}
+	if len(file.Tests) != 2 {
+		t.Fatalf("expected 2 tests, got %v", len(file.Tests))
+	}
+	money, reordered := file.Tests[0], file.Tests[1]
+	if money.Name != "test_money" || money.DisplayName != "Has Money" || money.Description != "The player has money" {
+		t.Errorf("unexpected test: %q, %q, %q", money.Name, money.DisplayName, money.Description)
+	}
+	if money.AcceptableFailRate != 0.5 || money.Success != "money > 0" || money.Position.Line != 7 {
+		t.Errorf("unexpected test fields: %v, %q, %v", money.AcceptableFailRate, money.Success, money.Position.Line)
+	}
+	if reordered.Name != "test_reordered" || reordered.Success != "always = yes" || reordered.Fail != "always = no" {
+		t.Errorf("unexpected test: %q, %q, %q", reordered.Name, reordered.Success, reordered.Fail)
+	}
+}
@@ -0,0 +1,116 @@
+package testing
+
+import "math"
+
+// z-score of the 95% confidence level used for pass rate intervals
+const confidenceZ = 1.96
+
+// Classification of a test across repeated test runs
+type Classification string
+
+const (
+	ClassificationStable    Classification = "stable"     // Passed in every run
+	ClassificationFlaky     Classification = "flaky"      // Passed in some runs and failed in others
+	ClassificationFailing   Classification = "failing"    // Failed in every run
+	ClassificationNoResults Classification = "no results" // No result in any run
+)
+
+// TestStatistics aggregates the results of a single test across repeated test runs
+type TestStatistics struct {
+	Test      *PdxTest
+	TestFile  *PdxTestFile
+	Successes int
+	Failures  int
+	Missing   int // Runs without a result for the test
+}
+
+// AggregateResults collects the statistics of every active test over all test runs
+func AggregateResults(runs []*ExecutionResults, testFiles []*PdxTestFile) []*TestStatistics {
+	statistics := make([]*TestStatistics, 0)
+	byTest := make(map[*PdxTest]*TestStatistics)
+	for _, file := range testFiles {
+		for _, test := range file.Tests {
+			if !file.IsTestActive(test) {
+				continue
+			}
+			testStatistics := &TestStatistics{Test: test, TestFile: file}
+			byTest[test] = testStatistics
+			statistics = append(statistics, testStatistics)
+		}
+	}
+	for _, run := range runs {
+		found := make(map[*PdxTest]bool)
+		for _, result := range run.TestResults {
+			testStatistics, ok := byTest[result.Test]
+			if !ok {
+				continue
+			}
+			found[result.Test] = true
+			if result.Success {
+				testStatistics.Successes++
+			} else {
+				testStatistics.Failures++
+			}
+		}
+		for test, testStatistics := range byTest {
+			if !found[test] {
+				testStatistics.Missing++
+			}
+		}
+	}
+	return statistics
+}
+
+// Runs returns the number of runs with a result for the test
+func (statistics *TestStatistics) Runs() int {
+	return statistics.Successes + statistics.Failures
+}
+
+// PassRate returns the share of runs (0 to 1) the test passed in
+func (statistics *TestStatistics) PassRate() float64 {
+	if statistics.Runs() == 0 {
+		return 0
+	}
+	return float64(statistics.Successes) / float64(statistics.Runs())
+}
+
+// FailRate returns the share of runs (0 to 1) the test failed in
+func (statistics *TestStatistics) FailRate() float64 {
+	if statistics.Runs() == 0 {
+		return 0
+	}
+	return float64(statistics.Failures) / float64(statistics.Runs())
+}
+
+// ConfidenceInterval returns the 95% Wilson score interval of the pass rate.
+// Unlike the normal approximation it stays meaningful for few runs and pass rates of 0 or 1.
+func (statistics *TestStatistics) ConfidenceInterval() (float64, float64) {
+	runs := float64(statistics.Runs())
+	if runs == 0 {
+		return 0, 1
+	}
+	rate := statistics.PassRate()
+	z2 := confidenceZ * confidenceZ
+	denominator := 1 + z2/runs
+	center := (rate + z2/(2*runs)) / denominator
+	margin := confidenceZ * math.Sqrt(rate*(1-rate)/runs+z2/(4*runs*runs)) / denominator
+	return math.Max(0, center-margin), math.Min(1, center+margin)
+}
+
+func (statistics *TestStatistics) Classification() Classification {
+	switch {
+	case statistics.Runs() == 0:
+		return ClassificationNoResults
+	case statistics.Failures == 0:
+		return ClassificationStable
+	case statistics.Successes == 0:
+		return ClassificationFailing
+	default:
+		return ClassificationFlaky
+	}
+}
+
+// ExceedsTolerance reports whether the observed fail rate is higher than the acceptable fail rate of the test
+func (statistics *TestStatistics) ExceedsTolerance() bool {
+	return statistics.Runs() > 0 && statistics.FailRate() > statistics.Test.AcceptableFailRate
+}
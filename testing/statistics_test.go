@@ -0,0 +1,59 @@
+package testing
+
+import (
+	"math"
+	gotesting "testing"
+)
+
+func TestConfidenceInterval(t *gotesting.T) {
+	tests := []struct {
+		name      string
+		successes int
+		failures  int
+		lower     float64
+		upper     float64
+	}{
+		{"no runs", 0, 0, 0, 1},
+		{"all passed", 10, 0, 0.722, 1},
+		{"all failed", 0, 10, 0, 0.278},
+		{"mostly passed", 8, 2, 0.490, 0.943},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *gotesting.T) {
+			statistics := &TestStatistics{Test: &PdxTest{}, Successes: test.successes, Failures: test.failures}
+			lower, upper := statistics.ConfidenceInterval()
+			if math.Abs(lower-test.lower) > 0.001 || math.Abs(upper-test.upper) > 0.001 {
+				t.Errorf("expected %.3f-%.3f, got %.3f-%.3f", test.lower, test.upper, lower, upper)
+			}
+		})
+	}
+}
+
+func TestExceedsTolerance(t *gotesting.T) {
+	tests := []struct {
+		name               string
+		successes          int
+		failures           int
+		acceptableFailRate float64
+		exceeds            bool
+	}{
+		{"no runs", 0, 0, 0, false},
+		{"all passed", 10, 0, 0, false},
+		{"all failed", 0, 10, 0, true},
+		{"all failed with full tolerance", 0, 10, 1, false},
+		{"within tolerance", 8, 2, 0.2, false},
+		{"above tolerance", 7, 3, 0.2, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *gotesting.T) {
+			statistics := &TestStatistics{
+				Test:      &PdxTest{AcceptableFailRate: test.acceptableFailRate},
+				Successes: test.successes,
+				Failures:  test.failures,
+			}
+			if actual := statistics.ExceedsTolerance(); actual != test.exceeds {
+				t.Errorf("expected %v, got %v", test.exceeds, actual)
+			}
+		})
+	}
+}
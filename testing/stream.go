@@ -0,0 +1,133 @@
+package testing
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	"bahmut.de/pdx-test-runner/logging"
+)
+
+// resultProgress counts the streamed results of a test run.
+// Parallel instances share the progress, so it always covers the whole test run.
+type resultProgress struct {
+	mutex    sync.Mutex
+	expected int // Number of active tests in the test run
+	passed   int
+	failed   int
+}
+
+func newResultProgress(expected int) *resultProgress {
+	return &resultProgress{expected: expected}
+}
+
+// resultStream tails the test result file of a running game and reports every result as soon as it is written
+type resultStream struct {
+	resultFile    string
+	label         string // Prefix of the reported results (e.g. the instance number, empty if not needed)
+	expectedTests map[string]bool
+	progress      *resultProgress
+	offset        int64           // Number of bytes of the result file already read
+	partial       []byte          // Incomplete last line of the result file
+	finishedTests map[string]bool // Expected tests with a result
+	results       int             // Number of results read
+}
+
+func newResultStream(resultFile, label string, expectedTests map[string]bool, progress *resultProgress) *resultStream {
+	return &resultStream{
+		resultFile:    resultFile,
+		label:         label,
+		expectedTests: expectedTests,
+		progress:      progress,
+		finishedTests: make(map[string]bool),
+	}
+}
+
+// read reads the lines appended to the result file since the last read and reports their results.
+// A missing result file is not an error, since the game creates it with the first result.
+func (stream *resultStream) read() error {
+	file, err := os.Open(stream.resultFile)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("could not open test results file: %v", err)
+	}
+	defer func(file *os.File) {
+		_ = file.Close()
+	}(file)
+
+	info, err := file.Stat()
+	if err != nil {
+		return fmt.Errorf("could not read test results file: %v", err)
+	}
+	if info.Size() < stream.offset {
+		// The result file was replaced, read it again from the start
+		stream.offset = 0
+		stream.partial = nil
+	}
+	if info.Size() == stream.offset {
+		return nil
+	}
+	_, err = file.Seek(stream.offset, io.SeekStart)
+	if err != nil {
+		return fmt.Errorf("could not read test results file: %v", err)
+	}
+	content, err := io.ReadAll(file)
+	if err != nil {
+		return fmt.Errorf("could not read test results file: %v", err)
+	}
+	stream.offset += int64(len(content))
+
+	content = append(stream.partial, content...)
+	end := bytes.LastIndexByte(content, '\n')
+	stream.partial = bytes.Clone(content[end+1:])
+	for _, line := range bytes.Split(content[:end+1], []byte{'\n'}) {
+		stream.readLine(string(bytes.TrimRight(line, "\r")))
+	}
+	return nil
+}
+
+func (stream *resultStream) readLine(line string) {
+	matches := regexTestResult.FindStringSubmatch(line)
+	if matches == nil {
+		return
+	}
+	stream.results++
+	if stream.expectedTests[matches[2]] {
+		stream.finishedTests[matches[2]] = true
+	}
+
+	progress := stream.progress
+	progress.mutex.Lock()
+	defer progress.mutex.Unlock()
+	success := matches[1] == testResultSuccess
+	if success {
+		progress.passed++
+	} else {
+		progress.failed++
+	}
+	counts := fmt.Sprintf(
+		"(%s%v%s passed, %s%v%s failed, %v/%v tests)",
+		logging.AnsiFgGreen, progress.passed, logging.AnsiAllDefault,
+		logging.AnsiFgLightRed, progress.failed, logging.AnsiAllDefault,
+		progress.passed+progress.failed, progress.expected,
+	)
+	if success {
+		logging.Infof("%s%s%sSuccess:%s %s (%s) %s", stream.label, logging.AnsiBoldOn, logging.AnsiFgGreen, logging.AnsiAllDefault, matches[2], matches[3], counts)
+	} else {
+		logging.Infof("%s%s%sFailure:%s %s (%s) %s", stream.label, logging.AnsiBoldOn, logging.AnsiFgLightRed, logging.AnsiAllDefault, matches[2], matches[3], counts)
+	}
+}
+
+// finished checks whether every expected test has a result line ([ OK ] or [ FAIL ]).
+// Without expected tests any result line counts as finished.
+func (stream *resultStream) finished() bool {
+	if len(stream.expectedTests) == 0 {
+		return stream.results > 0
+	}
+	return len(stream.finishedTests) == len(stream.expectedTests)
+}
@@ -0,0 +1,42 @@
+package testing
+
+import (
+	"bahmut.de/pdx-test-runner/config"
+	"bahmut.de/pdx-test-runner/logging"
+)
+
+// fileWatcher reports changes of a single file using filesystem notifications.
+// The directory of the file is watched, so the file does not need to exist yet.
+type fileWatcher struct {
+	changes chan struct{} // Receives a value after the file changed, consecutive changes may be merged
+	close   func() error
+}
+
+// Close stops watching the file
+func (watcher *fileWatcher) Close() error {
+	return watcher.close()
+}
+
+// notify reports a change without blocking, since an unreceived change already covers it
+func (watcher *fileWatcher) notify() {
+	select {
+	case watcher.changes <- struct{}{}:
+	default:
+	}
+}
+
+// watchResultFile watches the test result file with filesystem notifications if configured.
+// If notifications are not available the returned watcher never reports changes,
+// so the result file is only checked every poll interval.
+func watchResultFile(resultFile string, testConfig *config.TestRunnerConfig) *fileWatcher {
+	polling := &fileWatcher{close: func() error { return nil }}
+	if testConfig.FileWatching != config.FileWatchingNotify {
+		return polling
+	}
+	watcher, err := newFileWatcher(resultFile)
+	if err != nil {
+		logging.Warnf("Could not watch test results, checking every %v seconds instead: %v", testConfig.PollIntervalSeconds, err)
+		return polling
+	}
+	return watcher
+}
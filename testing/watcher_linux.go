@@ -0,0 +1,58 @@
+package testing
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// newFileWatcher watches the directory of the file with inotify
+func newFileWatcher(path string) (*fileWatcher, error) {
+	fd, err := unix.InotifyInit1(unix.IN_CLOEXEC | unix.IN_NONBLOCK)
+	if err != nil {
+		return nil, fmt.Errorf("could not initialize inotify: %v", err)
+	}
+	directory := filepath.Dir(path)
+	_, err = unix.InotifyAddWatch(fd, directory, unix.IN_CREATE|unix.IN_MODIFY|unix.IN_CLOSE_WRITE|unix.IN_MOVED_TO)
+	if err != nil {
+		_ = unix.Close(fd)
+		return nil, fmt.Errorf("could not watch directory (%s): %v", directory, err)
+	}
+
+	// A non-blocking file is handled by the runtime poller, so closing it stops a pending read
+	file := os.NewFile(uintptr(fd), "inotify")
+	watcher := &fileWatcher{
+		changes: make(chan struct{}, 1),
+		close:   file.Close,
+	}
+	go watcher.readInotifyEvents(file, filepath.Base(path))
+	return watcher, nil
+}
+
+func (watcher *fileWatcher) readInotifyEvents(file *os.File, name string) {
+	buffer := make([]byte, 64*1024)
+	for {
+		n, err := file.Read(buffer)
+		if err != nil {
+			// Either the watcher was closed or inotify failed, changes are still noticed by polling
+			return
+		}
+		for offset := 0; offset+unix.SizeofInotifyEvent <= n; {
+			event := (*unix.InotifyEvent)(unsafe.Pointer(&buffer[offset]))
+			nameStart := offset + unix.SizeofInotifyEvent
+			nameEnd := nameStart + int(event.Len)
+			if nameEnd > n {
+				break
+			}
+			eventName := string(bytes.TrimRight(buffer[nameStart:nameEnd], "\x00"))
+			if event.Mask&unix.IN_Q_OVERFLOW != 0 || eventName == name {
+				watcher.notify()
+			}
+			offset = nameEnd
+		}
+	}
+}
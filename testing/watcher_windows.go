@@ -0,0 +1,113 @@
+package testing
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+const watchedChanges = windows.FILE_NOTIFY_CHANGE_FILE_NAME | windows.FILE_NOTIFY_CHANGE_SIZE | windows.FILE_NOTIFY_CHANGE_LAST_WRITE
+
+// newFileWatcher watches the directory of the file with ReadDirectoryChangesW
+func newFileWatcher(path string) (*fileWatcher, error) {
+	directory := filepath.Dir(path)
+	directoryPointer, err := windows.UTF16PtrFromString(directory)
+	if err != nil {
+		return nil, fmt.Errorf("invalid directory (%s): %v", directory, err)
+	}
+	handle, err := windows.CreateFile(
+		directoryPointer,
+		windows.FILE_LIST_DIRECTORY,
+		windows.FILE_SHARE_READ|windows.FILE_SHARE_WRITE|windows.FILE_SHARE_DELETE,
+		nil,
+		windows.OPEN_EXISTING,
+		windows.FILE_FLAG_BACKUP_SEMANTICS|windows.FILE_FLAG_OVERLAPPED,
+		0,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("could not open directory (%s): %v", directory, err)
+	}
+	changed, err := windows.CreateEvent(nil, 1, 0, nil)
+	if err != nil {
+		_ = windows.CloseHandle(handle)
+		return nil, fmt.Errorf("could not create event: %v", err)
+	}
+	stop, err := windows.CreateEvent(nil, 1, 0, nil)
+	if err != nil {
+		_ = windows.CloseHandle(changed)
+		_ = windows.CloseHandle(handle)
+		return nil, fmt.Errorf("could not create event: %v", err)
+	}
+
+	stopped := make(chan struct{})
+	watcher := &fileWatcher{
+		changes: make(chan struct{}, 1),
+		close: func() error {
+			err := windows.SetEvent(stop)
+			if err != nil {
+				return fmt.Errorf("could not stop watching directory (%s): %v", directory, err)
+			}
+			<-stopped
+			return nil
+		},
+	}
+	go func() {
+		defer close(stopped)
+		defer func() {
+			_ = windows.CloseHandle(stop)
+			_ = windows.CloseHandle(changed)
+			_ = windows.CloseHandle(handle)
+		}()
+		watcher.readDirectoryChanges(handle, changed, stop, filepath.Base(path))
+	}()
+	return watcher, nil
+}
+
+func (watcher *fileWatcher) readDirectoryChanges(handle, changed, stop windows.Handle, name string) {
+	// The buffer has to be DWORD aligned
+	buffer := make([]uint32, 16*1024)
+	bufferSize := uint32(len(buffer) * 4)
+	for {
+		overlapped := &windows.Overlapped{HEvent: changed}
+		err := windows.ReadDirectoryChanges(handle, (*byte)(unsafe.Pointer(&buffer[0])), bufferSize, false, watchedChanges, nil, overlapped, 0)
+		if err != nil {
+			// Changes are still noticed by polling
+			return
+		}
+
+		event, err := windows.WaitForMultipleObjects([]windows.Handle{changed, stop}, false, windows.INFINITE)
+		var size uint32
+		if err != nil || event != windows.WAIT_OBJECT_0 {
+			// The pending read has to finish before the buffer is released
+			_ = windows.CancelIoEx(handle, overlapped)
+			_ = windows.GetOverlappedResult(handle, overlapped, &size, true)
+			return
+		}
+		err = windows.GetOverlappedResult(handle, overlapped, &size, false)
+		if err != nil {
+			return
+		}
+		_ = windows.ResetEvent(changed)
+		if size == 0 {
+			// Too many changes for the buffer, the changed files are unknown
+			watcher.notify()
+			continue
+		}
+
+		content := unsafe.Slice((*byte)(unsafe.Pointer(&buffer[0])), size)
+		for offset := uint32(0); offset < size; {
+			information := (*windows.FileNotifyInformation)(unsafe.Pointer(&content[offset]))
+			fileName := unsafe.Slice(&information.FileName, information.FileNameLength/2)
+			if strings.EqualFold(windows.UTF16ToString(fileName), name) {
+				watcher.notify()
+			}
+			if information.NextEntryOffset == 0 {
+				break
+			}
+			offset += information.NextEntryOffset
+		}
+	}
+}